@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// One switch modes let the game be played with a single button, the player's fire
+// key (or gamepad A, or a click). The cannon fires by itself in both.
+const (
+	oneSwitchOff   = "off"
+	oneSwitchSweep = "sweep" // The cannon sweeps back and forth, the switch turns it round
+	oneSwitchTrack = "track" // The cannon follows a column of aliens, the switch picks the next one
+)
+
+var oneSwitchModes = []string{oneSwitchOff, oneSwitchSweep, oneSwitchTrack}
+
+const (
+	minGameSpeed  = 0.3
+	gameSpeedStep = 0.1
+)
+
+// assistAllowed is whether the accessibility settings can be used in this game.
+// A network game runs at the same speed and on the same rules on both machines,
+// so they are off there.
+func (g *Game) assistAllowed() bool {
+	return g.net == nil
+}
+
+// assistActive is whether any accessibility setting is helping right now. Games
+// played with help are marked on the leaderboard, see Game.assisted.
+func (g *Game) assistActive() bool {
+	return g.assistAllowed() && (config.OneSwitch != oneSwitchOff || config.GameSpeed < 1 || g.noDeath())
+}
+
+// noDeath is whether bombs and the invasion can't take lives. Versus rounds have to
+// end somehow, so it doesn't apply there.
+func (g *Game) noDeath() bool {
+	return config.NoDeath && g.assistAllowed() && g.mode != modeVersus
+}
+
+// applyGameSpeed slows the game down to the game speed setting by running fewer
+// ticks a second. Each tick still stands for the same game time (see tickSeconds),
+// so the cannon, beams and aliens all slow down together.
+func (g *Game) applyGameSpeed() {
+	tps := ebiten.DefaultTPS
+	if g.assistAllowed() && g.playing() {
+		tps = int(math.Round(float64(ebiten.DefaultTPS) * config.GameSpeed))
+	}
+	if ebiten.TPS() != tps {
+		ebiten.SetTPS(tps)
+	}
+}
+
+// oneSwitchInput drives p's cannon for them, given whether their switch went down this tick.
+func (g *Game) oneSwitchInput(p *Player, pressed bool) Input {
+	in := inputFire // fire waits for the beam and the cooldown itself
+	switch config.OneSwitch {
+	case oneSwitchSweep:
+		if p.sweepDirection == 0 {
+			p.sweepDirection = 1
+		}
+		if pressed {
+			p.sweepDirection = -p.sweepDirection
+		}
+		// moveCannon stops the cannon dead at the edges, so turn round there
+		if p.cannonX <= 0 {
+			p.sweepDirection = 1
+		} else if p.cannonX >= float64(windowWidth-p.cannon.size.Dx()) {
+			p.sweepDirection = -1
+		}
+		if p.sweepDirection < 0 {
+			in |= inputLeft
+		} else {
+			in |= inputRight
+		}
+	case oneSwitchTrack:
+		b := p.board
+		if pressed || !b.columnAlive(p.trackColumn) {
+			p.trackColumn = b.nextColumn(p.trackColumn)
+		}
+		if x, ok := b.columnX(p.trackColumn); ok {
+			in |= g.steerTowards(p, x)
+		}
+	}
+	return in
+}
+
+// columnAlive is whether any alien is left in the formation's column.
+func (b *Board) columnAlive(column int) bool {
+	_, ok := b.columnX(column)
+	return ok
+}
+
+// columnX is the middle of the formation's column on the screen, if it has any aliens left.
+func (b *Board) columnX(column int) (int, bool) {
+	for i := column; i < len(b.aliens); i += ruleset.Columns {
+		if b.aliens[i].Status {
+			return b.aliens[i].Position.X + b.aliens[i].size.Dx()/2, true
+		}
+	}
+	return 0, false
+}
+
+// nextColumn is the next column to the right of column with aliens left in it,
+// going round to the left hand side. It stays put if the formation is empty.
+func (b *Board) nextColumn(column int) int {
+	for i := 1; i <= ruleset.Columns; i++ {
+		next := (column + i) % ruleset.Columns
+		if b.columnAlive(next) {
+			return next
+		}
+	}
+	return column
+}
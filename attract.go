@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Attract mode, for when nobody is playing: the title screen turns through its
+// pages, then a bot plays a silent demo game until someone touches the controls.
+const (
+	titlePageTicks   = 300 // How long each title page shows
+	titlePages       = 3   // The start picture, the points table and the high scores
+	demoTicks        = 3600
+	botDodgeDistance = 120 // How far above the cannon the bot starts dodging a bomb
+)
+
+// logoHeight is how much of the top of imgs/start.png is the logo, out of 100.
+const logoHeight = 43
+
+// anyInput is whether any key, mouse button, touch or gamepad button went down this tick.
+func anyInput() bool {
+	if len(inpututil.AppendJustPressedKeys(nil)) > 0 || len(inpututil.AppendJustPressedTouchIDs(nil)) > 0 {
+		return true
+	}
+	for button := ebiten.MouseButton0; button <= ebiten.MouseButtonMax; button++ {
+		if inpututil.IsMouseButtonJustPressed(button) {
+			return true
+		}
+	}
+	for button := ebiten.StandardGamepadButton(0); button <= ebiten.StandardGamepadButtonMax; button++ {
+		if padJustPressed(button) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateTitle turns the title pages, and starts the demo once they have all been shown.
+func (g *Game) updateTitle() {
+	g.titleTimer++
+	if anyInput() {
+		g.titleTimer = 0 // Someone is here, so back to the first page
+	}
+	if g.titleTimer >= titlePages*titlePageTicks {
+		g.switchScene(demoScene{mode: g.mode})
+	}
+}
+
+func (g *Game) drawTitle(screen *ebiten.Image) {
+	// All of the picture shows, whatever shape the screen is, on the plain background
+	drawBackdrop(screen, background)
+	op := &ebiten.DrawImageOptions{GeoM: fitPicture(g.startScreen)}
+	page := g.titleTimer / titlePageTicks
+	if page == 0 {
+		screen.DrawImage(g.startScreen, op) // The picture has its own press S to begin
+		return
+	}
+
+	// The other pages go under the logo
+	size := g.startScreen.Bounds().Size()
+	logo := g.startScreen.SubImage(image.Rect(0, 0, size.X, size.Y*logoHeight/100)).(*ebiten.Image)
+	screen.DrawImage(logo, op)
+
+	_, logoBottom := op.GeoM.Apply(0, float64(size.Y*logoHeight/100))
+	y := int(logoBottom) + windowHeight*5/100
+	if page == 1 {
+		g.drawPointsTable(screen, y)
+	} else {
+		g.drawTitleHighScores(screen, y)
+	}
+	line := tr("PRESS S TO BEGIN")
+	bounds := text.BoundString(g.gameFont, line)
+	text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight-ui(80), color.RGBA{0xff, 0x40, 0x40, 0xff})
+}
+
+// drawPointsTable shows what each alien is worth, like the cabinet's score advance table.
+func (g *Game) drawPointsTable(screen *ebiten.Image, y int) {
+	title := tr("SCORE ADVANCE TABLE")
+	bounds := text.BoundString(g.gameFont, title)
+	text.Draw(screen, title, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+	lineHeight := bounds.Dy() * 2
+
+	rows := []struct {
+		image *ebiten.Image
+		value string
+	}{
+		{src.SubImage(sheetRect(alien1Sprite)).(*ebiten.Image), tr("%d POINTS", ruleset.RowPoints[0])},
+		{src.SubImage(sheetRect(alien2Sprite)).(*ebiten.Image), tr("%d POINTS", ruleset.RowPoints[1])},
+		{src.SubImage(sheetRect(alien3Sprite)).(*ebiten.Image), tr("%d POINTS", ruleset.RowPoints[len(ruleset.RowPoints)-1])},
+	}
+	if ruleset.UFOValues != nil {
+		rows = append([]struct {
+			image *ebiten.Image
+			value string
+		}{{ufo.Filter, tr("MYSTERY")}}, rows...)
+	}
+	for _, row := range rows {
+		y += lineHeight
+		size := row.image.Bounds().Size()
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(windowWidth/2-ui(60)-size.X), float64(y-size.Y))
+		screen.DrawImage(row.image, op)
+		text.Draw(screen, row.value, g.gameFont, windowWidth/2-ui(30), y, color.White)
+	}
+}
+
+// drawTitleHighScores shows the leaderboard on the title screen.
+func (g *Game) drawTitleHighScores(screen *ebiten.Image, y int) {
+	title := tr("HIGH SCORES")
+	bounds := text.BoundString(g.gameFont, title)
+	text.Draw(screen, title, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+	lineHeight := bounds.Dy() * 3 / 2
+	if len(highScores) == 0 {
+		line := tr("NO SCORES YET")
+		bounds := text.BoundString(g.gameFont, line)
+		text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y+lineHeight*2, color.Gray{Y: 128})
+		return
+	}
+	y += lineHeight
+	for i, score := range highScores {
+		y += lineHeight
+		line := fmt.Sprintf("%d  %s  %s", i+1, score.Name, number(score.Score))
+		if score.Assisted {
+			line += "  " + tr("Assisted")
+		}
+		bounds := text.BoundString(g.gameFont, line)
+		text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+	}
+}
+
+// updateDemo plays one tick of the demo with the bot at the controls. Any input,
+// the end of the game or running out of time goes back to the title.
+func (g *Game) updateDemo() {
+	g.demoTimer++
+	if anyInput() || g.gameOver || g.demoTimer >= demoTicks {
+		g.switchScene(titleScene{})
+		return
+	}
+	p := g.player()
+	g.applyInput(p, g.botInput(p))
+	g.step()
+}
+
+// botInput plays the demo. It steps out from under bombs about to land on the
+// cannon, and otherwise lines up under the nearest column of aliens and fires.
+func (g *Game) botInput(p *Player) Input {
+	b := p.board
+	centre := p.cannon.Position.X + p.cannon.size.Dx()/2
+	for _, bomb := range b.bombs {
+		if !bomb.Status || bomb.Position.Y < playerYPosition-botDodgeDistance || bomb.Position.Y > playerYPosition {
+			continue
+		}
+		if bomb.Position.X < centre-p.cannon.size.Dx() || bomb.Position.X > centre+p.cannon.size.Dx() {
+			continue
+		}
+		// Away from the bomb, unless that's into the edge of the screen
+		if (bomb.Position.X >= centre && centre > p.cannon.size.Dx()) || centre > windowWidth-p.cannon.size.Dx() {
+			return inputLeft
+		}
+		return inputRight
+	}
+
+	target, found := 0, false
+	for column := 0; column < ruleset.Columns; column++ {
+		x, ok := b.columnX(column)
+		if ok && (!found || abs(x-centre) < abs(target-centre)) {
+			target, found = x, true
+		}
+	}
+	if !found {
+		return 0
+	}
+	in := g.steerTowards(p, target)
+	if abs(target-centre) < alienSize {
+		in |= inputFire
+	}
+	return in
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// demoScene is the attract mode demo. mode is the game mode to put back afterwards,
+// since the demo is always a one player game.
+type demoScene struct {
+	mode int
+}
+
+func (demoScene) enter(g *Game) {
+	g.demo = true
+	g.demoTimer = 0
+	g.startGame(modeSolo)
+}
+
+func (s demoScene) exit(g *Game) {
+	g.demo = false
+	g.mode = s.mode
+}
+
+func (demoScene) update(g *Game) {
+	g.updateDemo()
+}
+
+func (demoScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawGameScreen(screen)
+	line := tr("DEMO  PRESS ANY KEY")
+	bounds := text.BoundString(g.gameFont, line)
+	text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight/2, color.RGBA{0xff, 0x40, 0x40, 0xff})
+}
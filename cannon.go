@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Handling describes how a ship moves across the screen.
+// All values are per second, so the cannon feels the same at any tick rate.
+type Handling struct {
+	Acceleration float64 `json:"acceleration"` // pixels/second² gained while a direction is held
+	Friction     float64 `json:"friction"`     // pixels/second² lost when no direction is held
+	MaxSpeed     float64 `json:"maxSpeed"`     // top speed in pixels/second
+}
+
+// defaultHandling matches the old feel of 10 pixels per frame at 60 ticks per second.
+var defaultHandling = Handling{
+	Acceleration: 3000,
+	Friction:     4000,
+	MaxSpeed:     600,
+}
+
+// tickSeconds returns the game time one Update tick stands for, in seconds. The
+// game speed setting runs fewer ticks a second rather than longer ones, so the
+// cannon slows down with everything else.
+func tickSeconds() float64 {
+	return 1 / float64(ebiten.DefaultTPS)
+}
+
+// moveCannon applies one tick of movement to the player's laser cannon.
+// direction is -1 for left, 1 for right and 0 when no key is held.
+func (g *Game) moveCannon(p *Player, direction float64) {
+	dt := tickSeconds()
+	h := g.handling
+
+	if direction != 0 {
+		// Turning around uses friction as well, so changing direction feels snappy
+		if p.cannonVelocity*direction < 0 {
+			p.cannonVelocity += direction * h.Friction * dt
+		}
+		p.cannonVelocity += direction * h.Acceleration * dt
+	} else if p.cannonVelocity > 0 {
+		p.cannonVelocity = math.Max(0, p.cannonVelocity-h.Friction*dt)
+	} else if p.cannonVelocity < 0 {
+		p.cannonVelocity = math.Min(0, p.cannonVelocity+h.Friction*dt)
+	}
+	p.cannonVelocity = math.Max(-h.MaxSpeed, math.Min(h.MaxSpeed, p.cannonVelocity))
+
+	p.cannonX += p.cannonVelocity * dt
+
+	// Keep the cannon inside the playfield
+	minX := 0.0
+	maxX := float64(windowWidth - p.cannon.size.Dx())
+	if p.cannonX < minX {
+		p.cannonX = minX
+		p.cannonVelocity = 0
+	}
+	if p.cannonX > maxX {
+		p.cannonX = maxX
+		p.cannonVelocity = 0
+	}
+	p.cannon.Position.X = int(math.Round(p.cannonX))
+}
+
+// placeCannon puts the player's cannon at x and stops it.
+func (p *Player) placeCannon(x int) {
+	p.cannonX = float64(x)
+	p.cannonVelocity = 0
+	p.cannon.Position.X = x
+}
+
+// placeCannons puts every cannon back at its starting position.
+// In co-op the second cannon starts at the other side of the screen.
+func (g *Game) placeCannons() {
+	for i, p := range g.players {
+		x := 50
+		if g.mode == modeCoop && i == 1 {
+			x = windowWidth - 50 - p.cannon.size.Dx()
+		}
+		p.placeCannon(x)
+		p.cannon.Position.Y = playerYPosition
+	}
+}
+
+// applyInput moves and fires the player's cannon for one tick.
+func (g *Game) applyInput(p *Player, in Input) {
+	if p.fireCooldown > 0 {
+		p.fireCooldown--
+	}
+	g.moveCannon(p, in.direction())
+	if in&inputFire != 0 {
+		g.fire(p)
+	}
+}
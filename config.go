@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// configVersion is bumped whenever the config file layout changes, with a case
+// in migrateConfig for files from the version before.
+const configVersion = 6
+
+// Config is the player's settings, saved as JSON in the user's config directory.
+type Config struct {
+	Version     int    `json:"version"`
+	Rules       string `json:"rules"`       // rulesRelaxed or rulesClassic
+	SharedLives bool   `json:"sharedLives"` // Co-op players draw on one pool of lives
+
+	// Frames between pressing a key and it taking effect in a network game. More hides
+	// more of the round trip, fewer makes online co-op roll back more often.
+	NetInputDelay int `json:"netInputDelay"`
+
+	Keys            map[Action]ebiten.Key `json:"keys"`            // Key for each action, changed on the controls screen
+	GamepadDeadZone float64               `json:"gamepadDeadZone"` // How far a stick has to be pushed, from 0 to 1
+	ControlScheme   string                `json:"controlScheme"`   // schemeKeys, schemeMouse or schemeTouch
+
+	// The settings panel, see settings.go
+	MusicVolume   float64 `json:"musicVolume"` // From 0 to 1
+	SoundVolume   float64 `json:"soundVolume"` // From 0 to 1, for the sound effects
+	Difficulty    string  `json:"difficulty"`  // difficultyEasy, difficultyNormal or difficultyHard
+	Fullscreen    bool    `json:"fullscreen"`
+	WindowScale   int     `json:"windowScale"`   // 1 to maxWindowScale, or 0 for the rules' own scale
+	Scaling       string  `json:"scaling"`       // scalingFit or scalingInteger, see display.go
+	Language      string  `json:"language"`      // A catalogue's code, e.g. ga, or languageSystem, see locale.go
+	Palette       string  `json:"palette"`       // One of paletteNames, see palette.go
+	Background    string  `json:"background"`    // backgroundNormal, backgroundDim or backgroundPlain
+	ReducedMotion bool    `json:"reducedMotion"` // Keep flashing and animation safe, see effects.go
+
+	// The size the window was last dragged to, used when WindowScale is 0
+	WindowWidth  int `json:"windowWidth"`
+	WindowHeight int `json:"windowHeight"`
+
+	// Accessibility settings, see assist.go. Scores set with any of them on are marked as assisted.
+	OneSwitch string  `json:"oneSwitch"` // oneSwitchOff, oneSwitchSweep or oneSwitchTrack
+	GameSpeed float64 `json:"gameSpeed"` // From minGameSpeed to 1, full speed
+	NoDeath   bool    `json:"noDeath"`   // Bombs and the invasion don't cost lives
+}
+
+var config Config
+
+func defaultConfig() Config {
+	return Config{
+		Version:         configVersion,
+		Rules:           rulesRelaxed,
+		NetInputDelay:   netInputDelay,
+		Keys:            defaultBindings(),
+		GamepadDeadZone: defaultGamepadDeadZone,
+		ControlScheme:   schemeKeys,
+		MusicVolume:     defaultVolume,
+		SoundVolume:     defaultVolume,
+		Difficulty:      difficultyNormal,
+		Scaling:         scalingFit,
+		Language:        languageSystem,
+		Palette:         paletteNormal,
+		Background:      backgroundNormal,
+		OneSwitch:       oneSwitchOff,
+		GameSpeed:       1,
+	}
+}
+
+// configPath is where the config file lives, e.g. ~/.config/invaders/config.json on Linux.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "invaders", "config.json"), nil
+}
+
+// loadConfig reads the config file, writing the defaults if there isn't one yet.
+func loadConfig() {
+	config = defaultConfig()
+	path, err := configPath()
+	if err != nil {
+		log.Println("No config directory, using default settings:", err)
+		return
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		saveConfig()
+		return
+	}
+	if err != nil {
+		log.Println("Error reading config, using default settings:", err)
+		return
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		log.Println("Error reading config, using default settings:", err)
+		config = defaultConfig()
+	}
+	if config.Version > configVersion {
+		log.Fatalf("%s is from a newer version of the game (config version %d, this game knows up to %d)", path, config.Version, configVersion)
+	}
+	migrateConfig(config.Version)
+	config.Version = configVersion
+	checkBindings()
+	config.GamepadDeadZone = min(maxGamepadDeadZone, max(minGamepadDeadZone, config.GamepadDeadZone))
+	if !slices.Contains(controlSchemes, config.ControlScheme) {
+		config.ControlScheme = schemeKeys
+	}
+	checkSettings()
+}
+
+// migrateConfig brings a config file from an older version up to date, putting
+// in the defaults for each setting added since. Settings missing from the file
+// already have them from defaultConfig, so for now this only makes sure; a version
+// that renames a setting or changes what it means converts the old one here.
+func migrateConfig(from int) {
+	switch from {
+	case 0, 1: // Before the settings panel
+		config.MusicVolume, config.SoundVolume = defaultVolume, defaultVolume
+		config.Difficulty = difficultyNormal
+		config.Fullscreen, config.WindowScale = false, 0
+		fallthrough
+	case 2: // Before the scaling setting and the resizable window
+		config.Scaling = scalingFit
+		config.WindowWidth, config.WindowHeight = 0, 0
+		fallthrough
+	case 3: // Before translations
+		config.Language = languageSystem
+		fallthrough
+	case 4: // Before palettes
+		config.Palette, config.Background = paletteNormal, backgroundNormal
+		fallthrough
+	case 5: // Before Reduced motion
+		config.ReducedMotion = false
+	}
+}
+
+func saveConfig() {
+	path, err := configPath()
+	if err != nil {
+		return
+	}
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		log.Println("Error saving config:", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println("Error saving config:", err)
+		return
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		log.Println("Error saving config:", err)
+	}
+}
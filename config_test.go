@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	// Every setting away from its default, as if a newer setting's name had been
+	// used for something else
+	changed := func() Config {
+		c := defaultConfig()
+		c.MusicVolume, c.SoundVolume = 0.1, 0.2
+		c.Difficulty = difficultyHard
+		c.Fullscreen, c.WindowScale = true, 3
+		c.Scaling = scalingInteger
+		c.WindowWidth, c.WindowHeight = 100, 200
+		c.Language = "ga"
+		c.Palette, c.Background = paletteNames[len(paletteNames)-1], backgroundPlain
+		c.ReducedMotion = true
+		return c
+	}
+	defaults := defaultConfig()
+
+	for from := 0; from <= configVersion; from++ {
+		t.Run(fmt.Sprint("version ", from), func(t *testing.T) {
+			saved := config
+			defer func() { config = saved }()
+			config = changed()
+			migrateConfig(from)
+
+			settings := []struct {
+				name     string
+				since    int // The config version it was added in
+				migrated bool
+			}{
+				{"volume", 2, config.MusicVolume == defaults.MusicVolume && config.SoundVolume == defaults.SoundVolume && config.Difficulty == defaults.Difficulty && !config.Fullscreen && config.WindowScale == 0},
+				{"scaling", 3, config.Scaling == defaults.Scaling && config.WindowWidth == 0 && config.WindowHeight == 0},
+				{"language", 4, config.Language == defaults.Language},
+				{"palette", 5, config.Palette == defaults.Palette && config.Background == defaults.Background},
+				{"reduced motion", 6, !config.ReducedMotion},
+			}
+			for _, setting := range settings {
+				if want := from < setting.since; setting.migrated != want {
+					t.Errorf("%s migrated = %v, want %v", setting.name, setting.migrated, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Game modes picked on the ship select screen.
+const (
+	modeSolo        = iota
+	modeAlternating // two players taking turns, each with their own board
+	modeCoop        // two cannons on the screen at once against one formation
+	modeVersus      // player 2 drives the formation, see versus.go
+	modeLAN         // against another game on the network, started with -host or -join, see lan.go
+)
+
+// modeNames are the modes that can be picked on the ship select screen, in English, see tr.
+// The game font has no dash, so co-op is written "co op" on screen.
+var modeNames = []string{"1 player", "2 players taking turns", "2 players co op", "2 players versus"}
+
+// Controls is the keys, and the gamepad if one is plugged in, that a player moves and fires with.
+type Controls struct {
+	Left, Right, Fire ebiten.Key
+	gamepad           int  // Gamepad slot, see padSlots
+	pointer           bool // Also plays with the mouse or touch screen, see readInput
+}
+
+// In co-op the keyboard is split in half, player 1 on the left. Everyone else
+// plays with the keys bound to actions, see controlsFor.
+var coopControls = []Controls{
+	{Left: ebiten.KeyA, Right: ebiten.KeyD, Fire: ebiten.KeySpace, gamepad: 0},
+	{Left: ebiten.KeyArrowLeft, Right: ebiten.KeyArrowRight, Fire: ebiten.KeyEnter, gamepad: 1},
+}
+
+// playerColours tints each co-op player's cannon, beam, popups and score.
+var playerColours = []color.RGBA{
+	{0x40, 0xff, 0x40, 0xff},
+	{0x40, 0xc0, 0xff, 0xff},
+}
+
+// Input is what a player is pressing on one tick, kept as bits so it is small enough
+// to send over the network.
+type Input uint8
+
+const (
+	inputLeft Input = 1 << iota
+	inputRight
+	inputFire
+)
+
+// direction is -1 for left, 1 for right and 0 when neither or both are held.
+func (in Input) direction() float64 {
+	direction := 0.0
+	if in&inputRight != 0 {
+		direction++
+	}
+	if in&inputLeft != 0 {
+		direction--
+	}
+	return direction
+}
+
+// read is what the player is pressing right now.
+func (c Controls) read() Input {
+	var in Input
+	direction := c.direction()
+	if direction < 0 {
+		in |= inputLeft
+	}
+	if direction > 0 {
+		in |= inputRight
+	}
+	if c.firePressed() {
+		in |= inputFire
+	}
+	return in
+}
+
+// direction is -1 for left, 1 for right and 0 when neither is held.
+func (c Controls) direction() float64 {
+	direction := 0.0
+	if ebiten.IsKeyPressed(c.Right) {
+		direction++
+	}
+	if ebiten.IsKeyPressed(c.Left) {
+		direction--
+	}
+	if id, ok := c.gamepadID(); ok {
+		stick := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+		if ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftRight) || stick > config.GamepadDeadZone {
+			direction++
+		}
+		if ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftLeft) || stick < -config.GamepadDeadZone {
+			direction--
+		}
+	}
+	return math.Max(-1, math.Min(1, direction))
+}
+
+func (c Controls) firePressed() bool {
+	if inpututil.IsKeyJustPressed(c.Fire) {
+		return true
+	}
+	id, ok := c.gamepadID()
+	return ok && inpututil.IsStandardGamepadButtonJustPressed(id, ebiten.StandardGamepadButtonRightBottom)
+}
+
+// gamepadID finds the player's gamepad, if one is plugged in for them.
+func (c Controls) gamepadID() (ebiten.GamepadID, bool) {
+	if c.gamepad >= len(padSlots) || !padSlots[c.gamepad].connected {
+		return 0, false
+	}
+	return padSlots[c.gamepad].id, true
+}
+
+// activePlayers is every player with a cannon on the screen right now.
+func (g *Game) activePlayers() []*Player {
+	if g.mode != modeCoop {
+		return []*Player{g.player()}
+	}
+	var active []*Player
+	for _, p := range g.players {
+		if p.lives > 0 {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// team is the players who share p's lives: the whole team in co-op with shared lives,
+// otherwise just p.
+func (g *Game) team(p *Player) []*Player {
+	if g.mode == modeCoop && config.SharedLives {
+		return g.players
+	}
+	return []*Player{p}
+}
+
+// loseLife takes a life from p, or from everyone when co-op lives are shared.
+func (g *Game) loseLife(p *Player) {
+	g.rumble(p)
+	for _, teammate := range g.team(p) {
+		teammate.lives--
+	}
+}
+
+// nextShopper moves the shop on to the next co-op player still in the game.
+// It returns false once everyone has had their turn.
+func (g *Game) nextShopper() bool {
+	if g.mode != modeCoop {
+		return false
+	}
+	for i := g.current + 1; i < len(g.players); i++ {
+		if g.players[i].lives > 0 {
+			g.current = i
+			return true
+		}
+	}
+	return false
+}
+
+// coopEntry is the co-op leaderboard entry for the team: both names and their combined score.
+func (g *Game) coopEntry() HighScore {
+	var names []string
+	score := 0
+	for _, p := range g.players {
+		names = append(names, p.name)
+		score += p.score
+	}
+	// The game font has no ampersand
+	return HighScore{Name: strings.Join(names, " and "), Score: score, Ship: g.ship.Name, Rules: g.scoring, Assisted: g.assisted}
+}
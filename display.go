@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// The game is always drawn at the ruleset's logical resolution (windowWidth by
+// windowHeight), then scaled up to the window or screen by DrawFinalScreen,
+// keeping its shape and leaving black bars round the edge.
+const (
+	scalingFit     = "fit"     // As big as fits, so pixels can come out uneven
+	scalingInteger = "integer" // The biggest whole number of times that fits, so every pixel is the same size
+)
+
+var scalingModes = []string{scalingFit, scalingInteger}
+
+// ebitenGeoM is how ebiten fits the logical screen to the window, and screenGeoM
+// how DrawFinalScreen actually draws it. Set every frame.
+var ebitenGeoM, screenGeoM ebiten.GeoM
+
+// windowSettleTicks is how long the window has to stay one size after being
+// dragged before that size is remembered.
+const windowSettleTicks = 30
+
+// Layout keeps the logical resolution whatever the window's size, see DrawFinalScreen.
+func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	return windowWidth, windowHeight
+}
+
+// DrawFinalScreen scales the logical screen up to fill the window, by the scaling
+// setting, and letterboxes it. geoM is ebiten's own fit, which the mouse and touch
+// positions it gives are worked out from, see logicalPosition.
+func (g *Game) DrawFinalScreen(screen ebiten.FinalScreen, offscreen *ebiten.Image, geoM ebiten.GeoM) {
+	ebitenGeoM = geoM
+	screenGeoM = geoM
+	op := &ebiten.DrawImageOptions{}
+	op.Filter = ebiten.FilterLinear // Smooths the uneven pixels of a fractional scale
+	width, height := screen.Bounds().Dx(), screen.Bounds().Dy()
+	scale := min(float64(width)/float64(windowWidth), float64(height)/float64(windowHeight))
+	if config.Scaling == scalingInteger && scale >= 1 {
+		scale = math.Floor(scale)
+		screenGeoM = ebiten.GeoM{}
+		screenGeoM.Scale(scale, scale)
+		screenGeoM.Translate(math.Floor((float64(width)-float64(windowWidth)*scale)/2), math.Floor((float64(height)-float64(windowHeight)*scale)/2))
+	}
+	if scale == math.Floor(scale) {
+		op.Filter = ebiten.FilterNearest
+	}
+	op.GeoM = screenGeoM
+	screen.Fill(color.Black)
+	screen.DrawImage(offscreen, op)
+}
+
+// logicalPosition turns a cursor or touch position from ebiten into one on the
+// logical screen as it is actually drawn. They only differ with integer scaling,
+// which can draw the screen smaller than ebiten's own fit.
+func logicalPosition(x, y int) image.Point {
+	wx, wy := ebitenGeoM.Apply(float64(x), float64(y))
+	inverse := screenGeoM
+	inverse.Invert()
+	lx, ly := inverse.Apply(wx, wy)
+	return image.Pt(int(math.Floor(lx)), int(math.Floor(ly)))
+}
+
+func cursorPosition() image.Point {
+	return logicalPosition(ebiten.CursorPosition())
+}
+
+func touchPosition(id ebiten.TouchID) image.Point {
+	return logicalPosition(ebiten.TouchPosition(id))
+}
+
+// updateFullscreen goes in and out of fullscreen with F11 or Alt+Enter, on any
+// screen. It returns whether it did, so the Enter doesn't confirm anything as well.
+func (g *Game) updateFullscreen() bool {
+	alt := ebiten.IsKeyPressed(ebiten.KeyAlt)
+	if !inpututil.IsKeyJustPressed(ebiten.KeyF11) && !(alt && inpututil.IsKeyJustPressed(ebiten.KeyEnter)) {
+		return false
+	}
+	config.Fullscreen = !config.Fullscreen
+	ebiten.SetFullscreen(config.Fullscreen)
+	saveConfig()
+	return true
+}
+
+// watchWindow remembers the window's size once the player has dragged it to a new
+// one, so the game opens at that size next time.
+func (g *Game) watchWindow() {
+	if ebiten.IsFullscreen() {
+		return
+	}
+	width, height := ebiten.WindowSize()
+	if size := image.Pt(width, height); size != g.windowSize {
+		g.windowSize = size
+		g.windowSettle = windowSettleTicks
+		return
+	}
+	if g.windowSettle == 0 {
+		return
+	}
+	g.windowSettle--
+	if g.windowSettle == 0 && g.windowSize != windowSizeSetting() {
+		config.WindowScale = 0
+		config.WindowWidth, config.WindowHeight = width, height
+		saveConfig()
+	}
+}
+
+// windowSizeSetting is the window size the settings ask for: the window scale,
+// or failing that the size it was last dragged to, or failing that the rules' own scale.
+func windowSizeSetting() image.Point {
+	switch {
+	case config.WindowScale > 0:
+		return image.Pt(windowWidth*config.WindowScale, windowHeight*config.WindowScale)
+	case config.WindowWidth > 0 && config.WindowHeight > 0:
+		return image.Pt(config.WindowWidth, config.WindowHeight)
+	}
+	return image.Pt(windowWidth*ruleset.WindowScale, windowHeight*ruleset.WindowScale)
+}
+
+// applyDisplay sizes the window and goes in or out of fullscreen.
+func applyDisplay() {
+	size := windowSizeSetting()
+	ebiten.SetWindowSize(size.X, size.Y)
+	ebiten.SetFullscreen(config.Fullscreen)
+}
+
+func windowScaleName() string {
+	switch {
+	case config.WindowScale > 0:
+		return fmt.Sprintf("%dx", config.WindowScale)
+	case config.WindowWidth > 0 && config.WindowHeight > 0:
+		return tr("%d by %d", config.WindowWidth, config.WindowHeight)
+	}
+	return tr("default %dx", ruleset.WindowScale)
+}
+
+// drawBackdrop draws a picture over the whole screen at the same scale both ways,
+// cropping off whatever doesn't fit, so it keeps its shape at any resolution. The
+// background setting can dim it or leave it out.
+func drawBackdrop(screen, picture *ebiten.Image) {
+	if config.Background == backgroundPlain {
+		screen.Fill(color.Black)
+		return
+	}
+	size := picture.Bounds().Size()
+	scale := max(float64(windowWidth)/float64(size.X), float64(windowHeight)/float64(size.Y))
+	op := &ebiten.DrawImageOptions{}
+	if config.Background == backgroundDim {
+		op.ColorScale.Scale(backgroundDimming, backgroundDimming, backgroundDimming, 1)
+	}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate((float64(windowWidth)-float64(size.X)*scale)/2, (float64(windowHeight)-float64(size.Y)*scale)/2)
+	screen.DrawImage(picture, op)
+}
+
+// fitPicture scales a picture to fit inside the screen without cropping any of it,
+// at the same scale both ways, centred.
+func fitPicture(picture *ebiten.Image) ebiten.GeoM {
+	size := picture.Bounds().Size()
+	scale := min(float64(windowWidth)/float64(size.X), float64(windowHeight)/float64(size.Y))
+	var geoM ebiten.GeoM
+	geoM.Scale(scale, scale)
+	geoM.Translate((float64(windowWidth)-float64(size.X)*scale)/2, (float64(windowHeight)-float64(size.Y)*scale)/2)
+	return geoM
+}
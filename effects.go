@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image/color"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Anything that flashes, blinks or animates for show asks the effects budget how
+// to look, so the Reduced motion setting can keep it all safe for players who are
+// sensitive to flicker: nothing flashes more than three times a second, text
+// doesn't blink, explosions fade instead of strobing and sprites animate slowly.
+// The budget only changes how things are drawn, never the game, so it can differ
+// between the two machines in a network game. The game has no screen shake.
+const (
+	maxFlashesPerSecond = 3  // The most flashes the whole screen may have in a second, with Reduced motion on
+	safeAnimationTicks  = 30 // Ticks each frame of an animation shows for, with Reduced motion on
+	flickerTicks        = 4  // Ticks a flashing number is lit, then unlit, with Reduced motion off
+	safeExplosionLevel  = 0.5
+)
+
+// effectsBudget counts the flashes on the screen over the last second.
+type effectsBudget struct {
+	tick    int
+	flashes []int // The ticks flashes started on
+}
+
+var effects effectsBudget
+
+// update moves the budget on a tick, forgetting flashes more than a second old.
+func (e *effectsBudget) update() {
+	e.tick++
+	e.flashes = slices.DeleteFunc(e.flashes, func(tick int) bool {
+		return e.tick-tick >= ebiten.TPS()
+	})
+}
+
+// flash is whether something may start flashing now. With Reduced motion on, it
+// may if there have been fewer than maxFlashesPerSecond in the last second.
+func (e *effectsBudget) flash() bool {
+	if !config.ReducedMotion {
+		return true
+	}
+	if len(e.flashes) >= maxFlashesPerSecond {
+		return false
+	}
+	e.flashes = append(e.flashes, e.tick)
+	return true
+}
+
+// lit is whether something flashing, with ticks of its flash left, is lit now.
+// With Reduced motion on it stays lit for the whole flash, so it only flashes once.
+func (e *effectsBudget) lit(ticks int) bool {
+	if config.ReducedMotion {
+		return ticks > 0
+	}
+	return ticks/flickerTicks%2 == 1
+}
+
+// blink is whether blinking text is showing, ticks into blinking on and off every
+// period ticks. With Reduced motion on it always shows.
+func (e *effectsBudget) blink(ticks, period int) bool {
+	return config.ReducedMotion || ticks/period%2 == 0
+}
+
+// frame is which of an animation's frames shows at tick, normally a new one every tick.
+func (e *effectsBudget) frame(tick, frames int) int {
+	if config.ReducedMotion {
+		tick /= safeAnimationTicks
+	}
+	return tick % frames
+}
+
+// explosionTint is what to tint an explosion with ticks left to show, or nil for
+// none. With Reduced motion on it is see-through, so the explosion fades out from
+// half brightness instead of popping on and off, see drawSprite.
+func (e *effectsBudget) explosionTint(ticks int) color.Color {
+	if !config.ReducedMotion {
+		return nil
+	}
+	return color.Alpha{A: uint8(255 * safeExplosionLevel * float64(ticks) / explosionTicks)}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestFlashBudget(t *testing.T) {
+	saved := config.ReducedMotion
+	defer func() { config.ReducedMotion = saved }()
+
+	tests := []struct {
+		reducedMotion bool
+		asks          int // Flashes asked for on one tick
+		want          int // Flashes allowed
+	}{
+		{false, 1, 1},
+		{false, 10, 10},
+		{true, 1, 1},
+		{true, maxFlashesPerSecond, maxFlashesPerSecond},
+		{true, 10, maxFlashesPerSecond},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("reduced motion %v, %d flashes", test.reducedMotion, test.asks), func(t *testing.T) {
+			config.ReducedMotion = test.reducedMotion
+			e := &effectsBudget{}
+			allowed := 0
+			for range test.asks {
+				if e.flash() {
+					allowed++
+				}
+			}
+			if allowed != test.want {
+				t.Errorf("%d flashes allowed, want %d", allowed, test.want)
+			}
+
+			// Nothing more until a second after the first
+			for range ebiten.TPS() - 1 {
+				e.update()
+				if test.reducedMotion && allowed == maxFlashesPerSecond && e.flash() {
+					t.Fatalf("Flash allowed %d ticks later", e.tick)
+				}
+			}
+			e.update()
+			if !e.flash() {
+				t.Errorf("No flash allowed a second later")
+			}
+		})
+	}
+}
+
+func TestEffectsLook(t *testing.T) {
+	saved := config.ReducedMotion
+	defer func() { config.ReducedMotion = saved }()
+
+	tests := []struct {
+		reducedMotion bool
+		ticks         []int // Ticks left of a flash
+		lit           []bool
+		frames        []int // What frame of 2 each tick shows
+	}{
+		{false, []int{0, 3, 4, 7, 8}, []bool{false, false, true, true, false}, []int{0, 1, 0, 1, 0}},
+		{true, []int{0, 1, 4, 29, 30}, []bool{false, true, true, true, true}, []int{0, 0, 0, 0, 1}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint("reduced motion ", test.reducedMotion), func(t *testing.T) {
+			config.ReducedMotion = test.reducedMotion
+			for i, ticks := range test.ticks {
+				if got := effects.lit(ticks); got != test.lit[i] {
+					t.Errorf("lit(%d) = %v, want %v", ticks, got, test.lit[i])
+				}
+				if got := effects.frame(ticks, 2); got != test.frames[i] {
+					t.Errorf("frame(%d, 2) = %d, want %d", ticks, got, test.frames[i])
+				}
+			}
+			for ticks := range 120 {
+				if got, want := effects.blink(ticks, 60), test.reducedMotion || ticks < 60; got != want {
+					t.Fatalf("blink(%d, 60) = %v, want %v", ticks, got, want)
+				}
+			}
+			if tint := effects.explosionTint(explosionTicks); (tint != nil) != test.reducedMotion {
+				t.Errorf("explosionTint = %v", tint)
+			}
+		})
+	}
+}
+
+// TestHUDFlashes checks the HUD numbers that change on one tick only use up one
+// flash between them.
+func TestHUDFlashes(t *testing.T) {
+	g := newTestGame(t)
+	saved, savedEffects := config.ReducedMotion, effects
+	defer func() { config.ReducedMotion, effects = saved, savedEffects }()
+	config.ReducedMotion = true
+
+	p := g.player()
+	settle := func() {
+		for range max(ebiten.TPS(), hudFlashTicks) {
+			effects.update()
+			g.updateHUD()
+		}
+	}
+	effects = effectsBudget{}
+	g.updateHUD()
+	settle()
+
+	change := func() {
+		p.score += 100
+		p.credits += 10
+		p.lives++
+		g.updateHUD()
+	}
+	changed := []*hudValue{&g.hud.scores[0], &g.hud.credits, &g.hud.lives[0]}
+	for i := range maxFlashesPerSecond {
+		change()
+		if len(effects.flashes) != i+1 {
+			t.Fatalf("Flash %d: %d flashes in the budget, want %d", i, len(effects.flashes), i+1)
+		}
+		for _, v := range changed {
+			if !v.flashing() {
+				t.Errorf("Flash %d: a changed number isn't flashing", i)
+			}
+		}
+		if g.hud.wave.flashing() {
+			t.Errorf("Flash %d: the wave is flashing", i)
+		}
+
+		// Changing again while they flash keeps them on, without another flash
+		change()
+		if len(effects.flashes) != i+1 || !changed[0].flashing() {
+			t.Errorf("Flash %d: changing again made %d flashes, flashing %v", i, len(effects.flashes), changed[0].flashing())
+		}
+
+		// Let the flash finish, but not the second the budget counts
+		for range hudFlashTicks {
+			g.updateHUD()
+		}
+	}
+
+	// With the budget used up, nothing starts flashing
+	change()
+	for _, v := range changed {
+		if v.flashing() {
+			t.Errorf("A number flashed with the budget used up")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Gamepads go in slots, one for each player. A gamepad plugged in takes the first
+// empty slot, and keeps it until it is unplugged, so unplugging player 1's gamepad
+// doesn't hand player 2's over to player 1.
+const gamepadSlots = 2
+
+const (
+	defaultGamepadDeadZone = 0.5 // How far a stick has to be pushed before the cannon moves
+	minGamepadDeadZone     = 0.1
+	maxGamepadDeadZone     = 0.9
+	gamepadDeadZoneStep    = 0.05
+)
+
+type gamepadSlot struct {
+	id        ebiten.GamepadID
+	connected bool
+}
+
+var padSlots [gamepadSlots]gamepadSlot
+
+// actionButtons are the gamepad buttons for actions, on the standard layout.
+// Start both confirms on the menus and pauses during play.
+var actionButtons = map[Action][]ebiten.StandardGamepadButton{
+	actionMoveLeft:  {ebiten.StandardGamepadButtonLeftLeft},
+	actionMoveRight: {ebiten.StandardGamepadButtonLeftRight},
+	actionFire:      {ebiten.StandardGamepadButtonRightBottom},
+	actionPause:     {ebiten.StandardGamepadButtonCenterRight},
+	actionConfirm:   {ebiten.StandardGamepadButtonCenterRight},
+}
+
+// noticeTicks is how long a message like a gamepad being plugged in stays on the screen.
+const noticeTicks = 180
+
+// updateGamepads notices gamepads being plugged in and unplugged.
+func (g *Game) updateGamepads() {
+	for i := range padSlots {
+		if padSlots[i].connected && inpututil.IsGamepadJustDisconnected(padSlots[i].id) {
+			padSlots[i].connected = false
+			g.notify(tr("Player %d gamepad unplugged", i+1))
+			if g.playing() && g.net == nil {
+				g.pushScene(pausedScene{}) // Rather than leave a cannon with nobody at the controls
+			}
+		}
+	}
+
+	// Gamepads already plugged in when the game starts show up here on the first tick
+	for _, id := range inpututil.AppendJustConnectedGamepadIDs(nil) {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			g.notify(tr("This gamepad has no standard layout so it cant be used"))
+			continue
+		}
+		for i := range padSlots {
+			if !padSlots[i].connected {
+				padSlots[i] = gamepadSlot{id: id, connected: true}
+				g.notify(tr("Player %d gamepad connected", i+1))
+				break
+			}
+		}
+	}
+
+	if g.noticeTimer > 0 {
+		g.noticeTimer--
+	}
+}
+
+// playing is whether a game is being played right now, rather than a menu showing.
+func (g *Game) playing() bool {
+	_, ok := g.scene().(playingScene)
+	return ok && g.prompt == promptNone && !g.gameOver
+}
+
+// notify shows a message along the bottom of the screen for a few seconds.
+func (g *Game) notify(message string) {
+	g.notice = message
+	g.noticeTimer = noticeTicks
+}
+
+// padJustPressed is whether the button went down this tick on any player's gamepad.
+func padJustPressed(button ebiten.StandardGamepadButton) bool {
+	for _, slot := range padSlots {
+		if slot.connected && inpututil.IsStandardGamepadButtonJustPressed(slot.id, button) {
+			return true
+		}
+	}
+	return false
+}
+
+// menuUp and menuDown move around the lists on the menus, with the arrow keys or the d-pad.
+func menuUp() bool {
+	return inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) || padJustPressed(ebiten.StandardGamepadButtonLeftTop)
+}
+
+func menuDown() bool {
+	return inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) || padJustPressed(ebiten.StandardGamepadButtonLeftBottom)
+}
+
+// rumble shakes the player's gamepad when they lose a life, if it can. In a
+// network game only our own player's gamepad is ours to shake.
+func (g *Game) rumble(p *Player) {
+	if g.silent() || (g.net != nil && p != g.players[g.net.local]) {
+		return
+	}
+	if id, ok := p.controls.gamepadID(); ok {
+		ebiten.VibrateGamepad(id, &ebiten.VibrateGamepadOptions{
+			Duration:        300 * time.Millisecond,
+			StrongMagnitude: 1,
+			WeakMagnitude:   0.5,
+		})
+	}
+}
+
+// invaderPad is the versus invader's gamepad, player 2's slot.
+// D-pad picks the column, A fires, B marches and Y sends the UFO.
+var invaderPad = struct {
+	Left, Right, Fire, March, UFO ebiten.StandardGamepadButton
+}{
+	ebiten.StandardGamepadButtonLeftLeft,
+	ebiten.StandardGamepadButtonLeftRight,
+	ebiten.StandardGamepadButtonRightBottom,
+	ebiten.StandardGamepadButtonRightRight,
+	ebiten.StandardGamepadButtonRightTop,
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// nameWheel is the letters on the arcade style letter wheel, for entering a name
+// with a gamepad or the arrow keys.
+var nameWheel = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 ")
+
+// pendingScore is a score from the game just ended that is good enough for a
+// leaderboard, waiting for a name, see highScoreEntryScene.
+type pendingScore struct {
+	entry  HighScore
+	scores *[]HighScore
+	path   string
+	who    string // Whose score it is, e.g. PLAYER 2
+}
+
+// qualifies is whether score would go on the leaderboard. Nothing at all isn't a high score.
+func qualifies(scores []HighScore, score int) bool {
+	return score > 0 && (len(scores) < maxHighScores || score > scores[maxHighScores-1].Score)
+}
+
+// nameRune is whether r can go in a name. Anything printable can, in any language;
+// the high score files quote names with commas in them, see saveHighScores.
+func nameRune(r rune) bool {
+	return unicode.IsPrint(r)
+}
+
+// queueScore adds entry to the names to ask for, if it is good enough for scores.
+func (g *Game) queueScore(scores *[]HighScore, path string, entry HighScore, who string) {
+	if qualifies(*scores, entry.Score) {
+		g.pendingScores = append(g.pendingScores, pendingScore{entry: entry, scores: scores, path: path, who: who})
+	}
+}
+
+// nextPendingScore moves on to the next score waiting for a name, skipping any
+// pushed off the leaderboard by the ones before. It returns false when there are none left.
+func (g *Game) nextPendingScore() bool {
+	for len(g.pendingScores) > 0 {
+		pending := g.pendingScores[0]
+		if qualifies(*pending.scores, pending.entry.Score) {
+			g.nameInput = []rune(pending.entry.Name) // The player's name, their login name unless they typed one
+			g.wheelIndex = 0
+			return true
+		}
+		g.pendingScores = g.pendingScores[1:]
+	}
+	return false
+}
+
+// typeName adds r to the name being typed, if it fits and can go in a name.
+func (g *Game) typeName(r rune) {
+	if len(g.nameInput) < maxNameLength && nameRune(r) {
+		g.nameInput = append(g.nameInput, r)
+	}
+}
+
+// editName edits g.nameInput for a tick, and returns whether the name was confirmed.
+// Typing works for any keyboard. The letter wheel is for gamepads and arcade
+// sticks: Up and Down turn it, Right or A adds the letter and Left or B rubs one out.
+func (g *Game) editName() bool {
+	// Confirm can be bound to a letter, which shouldn't go in the name as well
+	confirm := justPressed(actionConfirm)
+	if !confirm {
+		for _, r := range ebiten.AppendInputChars(nil) {
+			g.typeName(r)
+		}
+	}
+	if menuUp() {
+		g.wheelIndex = (g.wheelIndex + 1) % len(nameWheel)
+	}
+	if menuDown() {
+		g.wheelIndex = (g.wheelIndex + len(nameWheel) - 1) % len(nameWheel)
+	}
+	step := menuStep()
+	if step > 0 || padJustPressed(ebiten.StandardGamepadButtonRightBottom) {
+		g.typeName(nameWheel[g.wheelIndex])
+	}
+	rubOut := step < 0 || padJustPressed(ebiten.StandardGamepadButtonRightRight) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace)
+	if rubOut && len(g.nameInput) > 0 {
+		g.nameInput = g.nameInput[:len(g.nameInput)-1]
+	}
+	return confirm
+}
+
+// updateHighScoreEntry takes a name for the leaderboard, see highScoreEntryScene.
+func (g *Game) updateHighScoreEntry(next scene) {
+	confirm := g.editName()
+	// Esc leaves the name as it was to start with
+	keep := inpututil.IsKeyJustPressed(ebiten.KeyEscape)
+	if !confirm && !keep {
+		return
+	}
+	pending := g.pendingScores[0]
+	if name := strings.TrimSpace(string(g.nameInput)); name != "" && !keep {
+		pending.entry.Name = name
+	}
+	addHighScore(pending.scores, pending.path, pending.entry)
+	g.pendingScores = g.pendingScores[1:]
+	if !g.nextPendingScore() {
+		g.switchScene(next)
+	}
+}
+
+func (g *Game) drawHighScoreEntry(screen *ebiten.Image) {
+	drawBackdrop(screen, background)
+
+	pending := g.pendingScores[0]
+	title := tr("NEW HIGH SCORE")
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	y := windowHeight / 4
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, y, color.White)
+	lineHeight := text.BoundString(g.gameFont, "A").Dy()
+
+	y += lineHeight * 3
+	score := fmt.Sprintf("%s  %s", pending.who, number(pending.entry.Score))
+	bounds := text.BoundString(g.gameFont, score)
+	text.Draw(screen, score, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+
+	// The name so far, with a block for the cursor
+	y += lineHeight * 3
+	name := string(g.nameInput)
+	bounds = text.BoundString(g.gameFont, name)
+	x := (windowWidth - bounds.Dx()) / 2
+	text.Draw(screen, name, g.gameFont, x, y, color.RGBA{0xff, 0xff, 0x40, 0xff})
+	if utf8.RuneCountInString(name) < maxNameLength {
+		ebitenutil.DrawRect(screen, float64(x+bounds.Dx()+2), float64(y-lineHeight), float64(lineHeight/2), float64(lineHeight), color.White)
+	}
+
+	y += lineHeight * 3
+	g.drawNameWheel(screen, y)
+
+	help := []string{
+		tr("Type your name  or Up and Down to pick a letter"),
+		tr("Right to add it  Left to rub one out"),
+		tr("%s to confirm  Esc to leave it as it was", keyName(actionConfirm)),
+	}
+	y = windowHeight - ui(60) - lineHeight*2*(len(help)-1)
+	for _, line := range help {
+		bounds := text.BoundString(g.gameFont, line)
+		text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+		y += lineHeight * 2
+	}
+}
+
+// drawNameWheel draws the letter wheel on the line at y, with the letter Right or
+// A would add in the middle.
+func (g *Game) drawNameWheel(screen *ebiten.Image, y int) {
+	lineHeight := text.BoundString(g.gameFont, "A").Dy()
+	spacing := lineHeight * 2
+	for i := -3; i <= 3; i++ {
+		r := nameWheel[(g.wheelIndex+i+len(nameWheel))%len(nameWheel)]
+		colour := color.Color(color.Gray{Y: 128})
+		if i == 0 {
+			colour = color.White
+		}
+		cx := windowWidth/2 + i*spacing
+		if r == ' ' {
+			// Space has nothing to see, so it's a bar
+			ebitenutil.DrawRect(screen, float64(cx-lineHeight/2), float64(y-2), float64(lineHeight), 2, colour)
+			continue
+		}
+		letter := string(r)
+		bounds := text.BoundString(g.gameFont, letter)
+		text.Draw(screen, letter, g.gameFont, cx-bounds.Dx()/2, y, colour)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestHighScoresRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		score HighScore
+	}{
+		{"plain", HighScore{Name: "Tester", Score: 100, Ship: "classic", Rules: scoringStandard}},
+		{"comma", HighScore{Name: "Keane, D", Score: 90, Ship: "classic", Rules: scoringStandard}},
+		{"quotes", HighScore{Name: `Say "hi"`, Score: 80, Rules: scoringStandard}},
+		{"quoted", HighScore{Name: `"Ace"`, Score: 70, Rules: scoringStandard}},
+		{"comma and quote", HighScore{Name: `a,"b`, Score: 60, Rules: scoringStandard}},
+		{"spaces", HighScore{Name: " spaced ", Score: 50, Rules: scoringStandard}},
+		{"not english", HighScore{Name: "Ó Briain 日本", Score: 40, Rules: scoringStandard}},
+		{"assisted", HighScore{Name: "Helped", Score: 30, Rules: scoringStandard, Assisted: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "highscores.txt")
+			saveHighScores(path, []HighScore{test.score})
+			got := readHighScores(path)
+			if len(got) != 1 || got[0] != test.score {
+				t.Errorf("Read back %+v, want %+v", got, test.score)
+			}
+		})
+	}
+
+	t.Run("all together", func(t *testing.T) {
+		var scores []HighScore
+		for _, test := range tests {
+			scores = append(scores, test.score)
+		}
+		path := filepath.Join(t.TempDir(), "highscores.txt")
+		saveHighScores(path, scores)
+		want := scores[:min(len(scores), maxHighScores)] // Already in order
+		if got := readHighScores(path); !slices.Equal(got, want) {
+			t.Errorf("Read back %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestReadHighScores(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []HighScore
+	}{
+		{"older file", "Tester,100\nOther,200,classic\n", []HighScore{
+			{Name: "Other", Score: 200, Ship: "classic", Rules: scoringStandard},
+			{Name: "Tester", Score: 100, Rules: scoringStandard},
+		}},
+		{"bad lines skipped", "Tester,lots\nOnly a name\nGood,10,,combo\n", []HighScore{
+			{Name: "Good", Score: 10, Rules: "combo"},
+		}},
+		{"stray quote", "Say \"hi,20\n", []HighScore{{Name: `Say "hi`, Score: 20, Rules: scoringStandard}}},
+		{"empty", "", []HighScore{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "highscores.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := readHighScores(path); !slices.Equal(got, test.want) {
+				t.Errorf("readHighScores(%q) = %+v, want %+v", test.content, got, test.want)
+			}
+		})
+	}
+
+	if got := readHighScores(filepath.Join(t.TempDir(), "missing.txt")); len(got) != 0 {
+		t.Errorf("readHighScores of a missing file = %+v, want none", got)
+	}
+}
+
+func TestAddHighScore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "highscores.txt")
+	var scores []HighScore
+	for i := range maxHighScores + 2 {
+		addHighScore(&scores, path, HighScore{Name: "Keane, D", Score: (i + 1) * 10, Rules: scoringStandard})
+	}
+	addHighScore(&scores, path, HighScore{Name: "Keane, D", Score: 30, Rules: scoringStandard}) // Already there
+	if len(scores) != maxHighScores {
+		t.Fatalf("%d scores kept, want %d", len(scores), maxHighScores)
+	}
+	if scores[0].Score != (maxHighScores+2)*10 || scores[maxHighScores-1].Score != 30 {
+		t.Errorf("Kept %+v, want the best %d", scores, maxHighScores)
+	}
+	if got := readHighScores(path); !slices.Equal(got, scores) {
+		t.Errorf("Saved %+v, want %+v", got, scores)
+	}
+}
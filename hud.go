@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// The HUD is drawn over the game in the game font: a row of labelled numbers along
+// the top, and each player's lives and power-ups in the bottom corners. Numbers
+// roll up to their new value and flash when they change, see hudValue.
+const (
+	hudRollDivisor = 6  // A rolling number closes this fraction of the gap each tick
+	hudFlashTicks  = 30 // How long a number flashes for after it changes
+	maxLifeIcons   = 5  // More lives than this still show as a number, with this many cannons
+)
+
+var hudFlashColour = color.RGBA{0xff, 0xff, 0x40, 0xff}
+
+// hudValue is a number on the HUD. Going up, it rolls to the new value instead of jumping.
+type hudValue struct {
+	shown int // What the HUD shows
+	value int
+	flash int // Ticks left of flashing
+}
+
+// update moves v on a tick towards value, returning whether value is new, so it
+// should flash, see updateHUD.
+func (v *hudValue) update(value int) bool {
+	changed := value != v.value
+	if changed {
+		if value < v.shown {
+			v.shown = value // A lost life or a new game, which don't roll
+		}
+		v.value = value
+	}
+	if v.shown < v.value {
+		v.shown += max(1, (v.value-v.shown)/hudRollDivisor)
+	}
+	if v.flash > 0 {
+		v.flash--
+	}
+	return changed
+}
+
+func (v *hudValue) flashing() bool {
+	return effects.lit(v.flash)
+}
+
+// hudState is the HUD's numbers, kept from tick to tick so they can roll.
+type hudState struct {
+	scores     [2]hudValue // Each player's score
+	lives      [2]hudValue
+	hiScore    hudValue
+	wave       hudValue
+	credits    hudValue
+	multiplier hudValue
+}
+
+// hudItem is one labelled value along the top of the HUD.
+type hudItem struct {
+	label  string
+	value  string
+	colour color.Color
+	flash  bool
+}
+
+// updateHUD moves the HUD's numbers on by a tick. Everything that changed on the
+// tick flashes together as one flash of the effects budget, so a kill that changes
+// the score, the high score and the multiplier at once only uses up one. Numbers
+// that are already flashing keep on without asking, as it isn't another flash.
+func (g *Game) updateHUD() {
+	if len(g.players) == 0 {
+		return
+	}
+	h := &g.hud
+	var changed []*hudValue
+	update := func(v *hudValue, value int) {
+		if v.update(value) {
+			changed = append(changed, v)
+		}
+	}
+	for i, p := range g.players[:min(len(g.players), len(h.scores))] {
+		update(&h.scores[i], p.score)
+		update(&h.lives[i], p.lives)
+	}
+	p := g.player()
+	update(&h.hiScore, g.hiScore())
+	update(&h.wave, p.board.wave)
+	update(&h.credits, p.credits)
+	update(&h.multiplier, g.multiplier(p))
+
+	newFlash := slices.ContainsFunc(changed, func(v *hudValue) bool { return v.flash == 0 })
+	if len(changed) > 0 && (!newFlash || effects.flash()) {
+		for _, v := range changed {
+			v.flash = hudFlashTicks
+		}
+	}
+}
+
+// hiScore is the top score on the leaderboard being played for, or the score
+// beating it right now.
+func (g *Game) hiScore() int {
+	scores, best := highScores, 0
+	if g.mode == modeCoop {
+		scores, best = coopHighScores, g.coopEntry().Score
+	} else {
+		for _, p := range g.players {
+			best = max(best, p.score)
+		}
+	}
+	if len(scores) > 0 {
+		best = max(best, scores[0].Score)
+	}
+	return best
+}
+
+// hudNumber is n as the HUD shows it, zero padded like the cabinet under the classic rules.
+func hudNumber(n int) string {
+	if ruleset.Name == rulesClassic {
+		return fmt.Sprintf("%04d", n)
+	}
+	return strconv.Itoa(n)
+}
+
+func valueItem(label string, v *hudValue) hudItem {
+	return hudItem{label: label, value: hudNumber(v.shown), colour: color.White, flash: v.flashing()}
+}
+
+// scoreHUD is the top of the HUD for every game but versus and LAN games: the
+// scores either side of the high score, like the cabinet, then the rest.
+func (g *Game) scoreHUD() []hudItem {
+	h := &g.hud
+	var items []hudItem
+	for i, p := range g.players[:min(len(g.players), len(h.scores))] {
+		item := valueItem(tr("SCORE"), &h.scores[i])
+		if len(g.players) > 1 {
+			item.label = tr("%dUP", i+1)
+		}
+		item.colour = p.colour
+		if g.mode == modeCoop && g.scoring == scoringCombo {
+			item.value += fmt.Sprintf(" x%d", g.multiplier(p))
+		} else if g.mode != modeCoop && i != g.current {
+			item.colour = color.Gray{Y: 128} // Waiting for their turn
+		}
+		items = append(items, item)
+	}
+	items = slices.Insert(items, 1, valueItem(tr("HI SCORE"), &h.hiScore))
+	items = append(items, valueItem(tr("WAVE"), &h.wave))
+	if g.scoring == scoringCombo && g.mode != modeCoop {
+		item := valueItem(tr("MULTIPLIER"), &h.multiplier)
+		item.value = "x" + item.value
+		items = append(items, item)
+	}
+	if ruleset.Shop && g.net == nil && g.mode != modeCoop {
+		items = append(items, valueItem(tr("CREDITS"), &h.credits))
+	}
+	return items
+}
+
+// hudPlayers is which players' lives and power-ups go in the bottom corners, by number.
+func (g *Game) hudPlayers() []int {
+	switch g.mode {
+	case modeCoop:
+		return []int{0, 1}
+	case modeVersus:
+		return []int{versusDefender}
+	}
+	return []int{g.current}
+}
+
+// powerUps is what p has bought in the shop this run, and their armour left this wave.
+func (p *Player) powerUps() []string {
+	var lines []string
+	for _, upgrade := range upgrades {
+		if level := p.upgradeLevel(upgrade.ID); level > 0 && upgrade.Effect.Armour == 0 {
+			lines = append(lines, fmt.Sprintf("%s %d", strings.ToUpper(upgrade.Name), level))
+		}
+	}
+	if p.armour > 0 {
+		lines = append(lines, tr("ARMOUR %d", p.armour))
+	}
+	return lines
+}
+
+func (g *Game) drawHUD(screen *ebiten.Image) {
+	var items []hudItem
+	var lines []string // Along the bottom, in the middle
+	switch {
+	case g.mode == modeLAN && g.net != nil:
+		items, lines = g.lanHUD()
+	case g.mode == modeVersus:
+		items, lines = g.versusHUD()
+	default:
+		items = g.scoreHUD()
+		if g.net != nil {
+			lines = g.rollbackStats()
+		}
+	}
+	if g.assisted {
+		lines = append(lines, tr("ASSISTED"))
+	}
+	g.drawHUDTop(screen, items)
+	g.drawHUDBottom(screen, lines)
+}
+
+// drawHUDTop spreads the items evenly along the top of the screen. With room above
+// the formation the labels go over the numbers, like the cabinet; otherwise they
+// go beside them. Items that don't fit are left off, from the end.
+func (g *Game) drawHUDTop(screen *ebiten.Image, items []hudItem) {
+	lineHeight := text.BoundString(g.hudFont, "0").Dy()
+	margin := ui(8)
+	stacked := ruleset.FormationY >= margin+lineHeight*4
+	width := func(item hudItem) int {
+		if stacked {
+			return max(text.BoundString(g.hudFont, item.label).Dx(), text.BoundString(g.hudFont, item.value).Dx())
+		}
+		return text.BoundString(g.hudFont, item.label+"  ").Dx() + text.BoundString(g.hudFont, item.value).Dx()
+	}
+	fits := func() bool {
+		for _, item := range items {
+			if width(item) > windowWidth/len(items)-margin {
+				return false
+			}
+		}
+		return true
+	}
+	for len(items) > 1 && !fits() {
+		items = items[:len(items)-1]
+	}
+
+	columnWidth := windowWidth / max(1, len(items))
+	for i, item := range items {
+		centre := columnWidth*i + columnWidth/2
+		colour := item.colour
+		if item.flash {
+			colour = hudFlashColour
+		}
+		if stacked {
+			g.drawHUDText(screen, item.label, centre, margin+lineHeight, 0, item.colour)
+			g.drawHUDText(screen, item.value, centre, margin+lineHeight*5/2, 0, colour)
+			continue
+		}
+		x := centre - width(item)/2
+		g.drawHUDText(screen, item.label, x, margin+lineHeight, -1, item.colour)
+		g.drawHUDText(screen, item.value, x+width(item), margin+lineHeight, 1, colour)
+	}
+}
+
+// drawHUDBottom puts each player's lives in a bottom corner with their power-ups
+// above, and lines up the middle.
+func (g *Game) drawHUDBottom(screen *ebiten.Image, lines []string) {
+	lineHeight := text.BoundString(g.hudFont, "0").Dy()
+	margin := ui(8)
+	for side, i := range g.hudPlayers() {
+		p := g.players[i]
+		x, align := margin, -1
+		if side == 1 {
+			x, align = windowWidth-margin, 1
+		}
+		y := windowHeight - margin
+		g.drawLives(screen, p, &g.hud.lives[i], x, y, align)
+		for _, line := range p.powerUps() {
+			y -= lineHeight * 3 / 2
+			g.drawHUDText(screen, line, x, y, align, p.colour)
+		}
+	}
+	y := windowHeight - margin
+	for i := len(lines) - 1; i >= 0; i-- {
+		g.drawHUDText(screen, lines[i], windowWidth/2, y, 0, color.White)
+		y -= lineHeight * 3 / 2
+	}
+}
+
+// drawLives shows how many lives p has left, as a number and a row of their
+// cannon, from x. align is as for drawHUDText.
+func (g *Game) drawLives(screen *ebiten.Image, p *Player, v *hudValue, x, y, align int) {
+	colour := color.Color(p.colour)
+	if v.flashing() {
+		colour = hudFlashColour
+	}
+	lineHeight := text.BoundString(g.hudFont, "0").Dy()
+	icon := p.cannon.Filter
+	size := icon.Bounds().Size()
+	scale := min(1, float64(lineHeight)/float64(size.Y))
+	iconWidth := int(float64(size.X) * scale)
+	gap := max(2, ui(6))
+	number := strconv.Itoa(v.shown)
+	icons := min(v.shown, maxLifeIcons)
+	width := text.BoundString(g.hudFont, number).Dx() + icons*(gap+iconWidth)
+	if align > 0 {
+		x -= width
+	}
+
+	x = g.drawHUDText(screen, number, x, y, -1, colour) + gap
+	for range icons {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(scale, scale)
+		op.GeoM.Translate(float64(x), float64(y)-float64(size.Y)*scale)
+		op.ColorScale.ScaleWithColor(paletteColour(colour, y))
+		screen.DrawImage(icon, op)
+		x += iconWidth + gap
+	}
+}
+
+// drawHUDText draws s with its baseline at y, starting at x for an align of -1,
+// centred on x for 0 and ending at x for 1. It returns where the text ends.
+func (g *Game) drawHUDText(screen *ebiten.Image, s string, x, y, align int, colour color.Color) int {
+	face := g.hudFont
+	width := text.BoundString(face, s).Dx()
+	switch align {
+	case 0:
+		x -= width / 2
+	case 1:
+		x -= width
+	}
+	text.Draw(screen, s, face, x, y, paletteColour(colour, y))
+	return x + width
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"image/color"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Action is something the player does, bound to a key in the config file.
+type Action string
+
+const (
+	actionMoveLeft  Action = "moveLeft"
+	actionMoveRight Action = "moveRight"
+	actionFire      Action = "fire"
+	actionPause     Action = "pause"
+	actionQuit      Action = "quit" // Give up the game in progress
+	actionConfirm   Action = "confirm"
+)
+
+// actions are listed in this order on the key binding screen.
+var actions = []Action{actionMoveLeft, actionMoveRight, actionFire, actionPause, actionQuit, actionConfirm}
+
+// actionNames are the actions' names in English, see tr.
+var actionNames = map[Action]string{
+	actionMoveLeft:  "Move left",
+	actionMoveRight: "Move right",
+	actionFire:      "Fire",
+	actionPause:     "Pause",
+	actionQuit:      "Quit game",
+	actionConfirm:   "Confirm",
+}
+
+// reservedKey is a key the game uses for something of its own, see reservedKeys.
+type reservedKey struct {
+	reason string   // Why it can't be bound, in English with %s for the key, see tr
+	allow  []Action // Actions that can have it anyway, as they do the same thing or aren't used where it is
+}
+
+// playActions are replaced by the split keyboard in local co-op, so they can share its keys.
+var playActions = []Action{actionMoveLeft, actionMoveRight, actionFire}
+
+// reservedKeys are every key the game reads itself instead of through a binding.
+// Keep it up to date with coopControls, invaderKeys, updateFullscreen and the
+// keys the screens use.
+var reservedKeys = map[ebiten.Key]reservedKey{
+	// Menus, the key binding screen and the high score name
+	ebiten.KeyBackspace:  {reason: "%s is used by the menus"},
+	ebiten.KeyArrowUp:    {reason: "%s is used by the menus"},
+	ebiten.KeyArrowDown:  {reason: "%s is used by the menus"},
+	ebiten.KeyArrowLeft:  {reason: "%s is used by the menus", allow: playActions}, // And player 2 in co op
+	ebiten.KeyArrowRight: {reason: "%s is used by the menus", allow: playActions},
+	ebiten.KeyEscape:     {reason: "%s goes back", allow: []Action{actionPause}},
+	ebiten.KeyR:          {reason: "%s puts the defaults back"},
+
+	// The title screen, the players prompt and the ship select screen
+	ebiten.KeyK: {reason: "%s opens the controls"},
+	ebiten.KeyO: {reason: "%s opens the settings"},
+	ebiten.Key1: {reason: "%s starts a game"},
+	ebiten.Key2: {reason: "%s starts a game"},
+	ebiten.KeyC: {reason: "%s changes the scoring"},
+	ebiten.KeyP: {reason: "%s changes the players"},
+	ebiten.KeyL: {reason: "%s changes the lives in co op"},
+
+	// Local co-op's split keyboard and the versus invader
+	ebiten.KeyA:     {reason: "%s is used in co op and versus"},
+	ebiten.KeyD:     {reason: "%s is used in co op and versus"},
+	ebiten.KeySpace: {reason: "%s is for player 1 in co op", allow: playActions},
+	ebiten.KeyEnter: {reason: "%s is for player 2 in co op", allow: append([]Action{actionConfirm}, playActions...)}, // And Alt Enter for fullscreen
+	ebiten.KeyW:     {reason: "%s is for the invader in versus"},
+	ebiten.KeyS:     {reason: "%s is for the invader in versus"}, // And starts a game from the title screen
+	ebiten.KeyE:     {reason: "%s is for the invader in versus"},
+
+	// Fullscreen, see updateFullscreen
+	ebiten.KeyF11:      {reason: "%s switches fullscreen"},
+	ebiten.KeyAltLeft:  {reason: "%s switches fullscreen"},
+	ebiten.KeyAltRight: {reason: "%s switches fullscreen"},
+}
+
+// defaultBindings are the keys the game has always used.
+func defaultBindings() map[Action]ebiten.Key {
+	return map[Action]ebiten.Key{
+		actionMoveLeft:  ebiten.KeyArrowLeft,
+		actionMoveRight: ebiten.KeyArrowRight,
+		actionFire:      ebiten.KeySpace,
+		actionPause:     ebiten.KeyEscape,
+		actionQuit:      ebiten.KeyQ,
+		actionConfirm:   ebiten.KeyEnter,
+	}
+}
+
+// justPressed is whether the action's key, or its gamepad button, went down this tick.
+func justPressed(a Action) bool {
+	if inpututil.IsKeyJustPressed(config.Keys[a]) {
+		return true
+	}
+	for _, button := range actionButtons[a] {
+		if padJustPressed(button) {
+			return true
+		}
+	}
+	return pointerJustPressed(a)
+}
+
+// keyName is the action's key as shown on screen. Key names are letters and digits
+// only, so the game font can draw them.
+func keyName(a Action) string {
+	return config.Keys[a].String()
+}
+
+// bindingConflict says why key can't be bound to a, if it can't.
+func bindingConflict(a Action, key ebiten.Key) (string, bool) {
+	if reserved, ok := reservedKeys[key]; ok && !slices.Contains(reserved.allow, a) {
+		return tr(reserved.reason, key), true
+	}
+	for _, other := range actions {
+		if other != a && config.Keys[other] == key {
+			return tr("%s is already %s", key, tr(actionNames[other])), true
+		}
+	}
+	return "", false
+}
+
+// checkBindings puts the default keys back if the config file's bindings clash,
+// since a clash could leave the game with no way to fire or confirm.
+func checkBindings() {
+	for _, a := range actions {
+		if reason, clash := bindingConflict(a, config.Keys[a]); clash {
+			log.Println("Key bindings in the config clash, using the default keys:", reason)
+			config.Keys = defaultBindings()
+			return
+		}
+	}
+}
+
+// controlsFor is the controls player number (counting from 0) plays with.
+// Local co-op splits the keyboard, everyone else uses the bound keys. With two
+// players at one computer each has their own gamepad slot.
+func (g *Game) controlsFor(number int) Controls {
+	if g.mode == modeCoop && g.net == nil {
+		controls := coopControls[number]
+		controls.pointer = number == 0 // There is only one mouse
+		return controls
+	}
+	controls := Controls{Left: config.Keys[actionMoveLeft], Right: config.Keys[actionMoveRight], Fire: config.Keys[actionFire], gamepad: 0, pointer: true}
+	if g.mode == modeAlternating {
+		controls.gamepad = number
+	}
+	return controls
+}
+
+// controlSettings are the rows after the actions on the controls screen.
+var controlSettings = []setting{
+	{name: "Stick dead zone", value: func() string { return percent(config.GamepadDeadZone) }, change: func(step int) {
+		config.GamepadDeadZone = math.Round(min(maxGamepadDeadZone, max(minGamepadDeadZone, config.GamepadDeadZone+float64(step)*gamepadDeadZoneStep))*100) / 100
+	}},
+	{name: "Control scheme", value: func() string { return tr(config.ControlScheme) }, change: func(step int) {
+		config.ControlScheme = cycle(controlSchemes, config.ControlScheme, step)
+	}},
+}
+
+func (g *Game) updateKeyBindings() {
+	if g.capturingKey {
+		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+			g.capturingKey = false
+			g.bindingMessage = ""
+			return
+		}
+		keys := inpututil.AppendJustPressedKeys(nil)
+		if len(keys) == 0 {
+			return
+		}
+		a := actions[g.bindingIndex]
+		if reason, clash := bindingConflict(a, keys[0]); clash {
+			g.bindingMessage = reason // Keep waiting for a key that's free
+			return
+		}
+		config.Keys[a] = keys[0]
+		saveConfig()
+		g.capturingKey = false
+		g.bindingMessage = ""
+		for i, p := range g.players {
+			p.controls = g.controlsFor(i)
+		}
+		return
+	}
+
+	rows := len(actions) + len(controlSettings)
+	if menuDown() {
+		g.bindingIndex = (g.bindingIndex + 1) % rows
+	}
+	if menuUp() {
+		g.bindingIndex = (g.bindingIndex + rows - 1) % rows
+	}
+	switch {
+	case g.bindingIndex >= len(actions):
+		if step := menuStep(); step != 0 {
+			controlSettings[g.bindingIndex-len(actions)].change(step)
+			saveConfig()
+		}
+	case justPressed(actionConfirm):
+		g.capturingKey = true
+		g.bindingMessage = ""
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		config.Keys = defaultBindings()
+		config.GamepadDeadZone = defaultGamepadDeadZone
+		config.ControlScheme = schemeKeys
+		saveConfig()
+		for i, p := range g.players {
+			p.controls = g.controlsFor(i)
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.popScene()
+	}
+}
+
+func (g *Game) drawKeyBindings(screen *ebiten.Image) {
+	drawBackdrop(screen, background)
+
+	title := tr("CONTROLS")
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, ui(100), color.White)
+
+	lineHeight := text.BoundString(g.gameFont, "A").Dy() * 3 / 2
+	y := ui(170)
+	for i, a := range actions {
+		colour := color.Color(color.Gray{Y: 128})
+		key := keyName(a)
+		if i == g.bindingIndex {
+			colour = color.White
+			if g.capturingKey {
+				key = tr("Press a key")
+			}
+		}
+		text.Draw(screen, tr(actionNames[a]), g.gameFont, windowWidth/4, y, colour)
+		text.Draw(screen, key, g.gameFont, windowWidth/2+ui(40), y, colour)
+		y += lineHeight
+	}
+
+	y = g.drawSettingRows(screen, controlSettings, g.bindingIndex-len(actions), y)
+
+	for i, slot := range padSlots {
+		state := tr("none")
+		if slot.connected {
+			state = tr("connected")
+		}
+		text.Draw(screen, tr("Player %d gamepad", i+1), g.gameFont, windowWidth/4, y, color.Gray{Y: 128})
+		text.Draw(screen, state, g.gameFont, windowWidth/2+ui(40), y, color.Gray{Y: 128})
+		y += lineHeight
+	}
+
+	if g.bindingMessage != "" {
+		bounds := text.BoundString(g.gameFont, g.bindingMessage)
+		text.Draw(screen, g.bindingMessage, g.gameFont, (windowWidth-bounds.Dx())/2, y+lineHeight, color.RGBA{0xff, 0x40, 0x40, 0xff})
+	}
+
+	help := tr("Up or Down to choose  %s to change  R to reset  Esc to go back", keyName(actionConfirm))
+	if g.bindingIndex >= len(actions) {
+		help = tr("Up or Down to choose  Left or Right to change  R to reset  Esc to go back")
+	}
+	if g.capturingKey {
+		help = tr("Press the new key  Backspace to cancel")
+	}
+	helpBounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-helpBounds.Dx())/2, windowHeight-ui(60), color.White)
+}
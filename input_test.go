@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestBindingConflict(t *testing.T) {
+	newTestGame(t)
+	config.Keys = defaultBindings()
+	for _, a := range actions {
+		if reason, clash := bindingConflict(a, config.Keys[a]); clash {
+			t.Errorf("Default key for %s refused: %s", a, reason)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		action Action
+		key    ebiten.Key
+		clash  bool
+	}{
+		{"free key", actionFire, ebiten.KeyZ, false},
+		{"same key again", actionFire, ebiten.KeySpace, false},
+		{"bound to another action", actionFire, ebiten.KeyQ, true},
+		{"menu key", actionFire, ebiten.KeyBackspace, true},
+		{"arrow for moving", actionMoveLeft, ebiten.KeyArrowRight, true}, // Bound to move right
+		{"arrow for quitting", actionQuit, ebiten.KeyArrowLeft, true},
+		{"reset", actionFire, ebiten.KeyR, true},
+		{"controls", actionQuit, ebiten.KeyK, true},
+		{"settings", actionQuit, ebiten.KeyO, true},
+		{"ship select", actionFire, ebiten.KeyC, true},
+		{"players", actionFire, ebiten.KeyP, true},
+		{"co op lives", actionFire, ebiten.KeyL, true},
+		{"start", actionFire, ebiten.Key1, true},
+		{"co op left", actionMoveLeft, ebiten.KeyA, true},
+		{"co op right", actionMoveRight, ebiten.KeyD, true},
+		{"invader", actionFire, ebiten.KeyW, true},
+		{"invader march", actionQuit, ebiten.KeyS, true},
+		{"invader UFO", actionConfirm, ebiten.KeyE, true},
+		{"fullscreen", actionPause, ebiten.KeyF11, true},
+		{"alt", actionFire, ebiten.KeyAltLeft, true},
+		{"escape to pause", actionPause, ebiten.KeyEscape, false},
+		{"escape to fire", actionFire, ebiten.KeyEscape, true},
+		{"space to pause", actionPause, ebiten.KeySpace, true},
+		{"enter to quit", actionQuit, ebiten.KeyEnter, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, clash := bindingConflict(test.action, test.key)
+			if clash != test.clash {
+				t.Errorf("bindingConflict(%s, %s) = %q, %v, want %v", test.action, test.key, reason, clash, test.clash)
+			}
+			if clash && reason == "" {
+				t.Errorf("bindingConflict(%s, %s) gave no reason", test.action, test.key)
+			}
+		})
+	}
+}
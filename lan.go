@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// In a LAN game each player clears their own formation, and every few aliens
+// they shoot come down the other player's screen as an attacker.
+const (
+	lanKillsPerAttacker = 3
+	lanAttackerSpeed    = 3  // Pixels per tick
+	lanAttackerGapTicks = 30 // Ticks between attackers arriving
+	lanAttackerPoints   = 10
+)
+
+// stepLAN plays one frame of a LAN game with both players' inputs for it.
+// Both games run this for both players, so they stay the same.
+func (g *Game) stepLAN(inputs [2]Input) {
+	for i, p := range g.players {
+		if p.lives > 0 {
+			g.applyInput(p, inputs[i])
+		}
+	}
+
+	for i, p := range g.players {
+		b := p.board
+		opponent := g.players[1-i]
+		g.moveFormation(b)
+		if ruleset.ColumnFiringTable != nil {
+			g.fireColumnBomb(b, []*Player{p})
+		}
+		alive := b.aliveAliens()
+		g.simulate(b, []*Player{p})
+		g.updateAttackers(p)
+
+		// Shot aliens go to the other player
+		b.kills += alive - b.aliveAliens()
+		for b.kills >= lanKillsPerAttacker {
+			b.kills -= lanKillsPerAttacker
+			opponent.board.pendingAttackers++
+		}
+		if b.cleared() {
+			b.wave++
+			g.startWave(b)
+		}
+	}
+	g.updatePopups()
+	g.loop++
+
+	out := [2]bool{g.players[0].lives <= 0, g.players[1].lives <= 0}
+	if out[0] || out[1] {
+		g.gameOver = true
+		if out[0] && !out[1] {
+			g.net.winner = 1
+		} else if out[1] && !out[0] {
+			g.net.winner = 0
+		}
+		if endGameSound != nil {
+			endGameSound.Rewind()
+			endGameSound.Play()
+		}
+	}
+}
+
+// updateAttackers brings in the attackers sent by the other player and flies them down the screen.
+func (g *Game) updateAttackers(p *Player) {
+	b := p.board
+	if b.pendingAttackers > 0 && g.loop%lanAttackerGapTicks == 0 {
+		b.pendingAttackers--
+		x := ruleset.FormationX + g.rng.IntN(ruleset.Columns)*ruleset.ColumnSpacing
+		b.attackers = append(b.attackers, createAlien(x, ruleset.FormationY, alien1Sprite, alien1aSprite, lanAttackerPoints))
+	}
+
+	attackers := b.attackers[:0]
+	for _, attacker := range b.attackers {
+		attacker.Position.Y += lanAttackerSpeed
+		if p.beam.Status && collide(attacker, p.beam) {
+			b.explode(attacker.FilterE, attacker.Position)
+			g.play(b, explosionSound)
+			p.score += attacker.Points
+			p.resetBeam()
+			continue
+		}
+		if p.lives > 0 && collide(attacker, p.cannon) {
+			b.explode(p.cannon.FilterE, p.cannon.Position)
+			g.play(b, shipExplosionSound)
+			g.loseLife(p)
+			g.breakCombo(p)
+			p.resetBeam()
+			continue
+		}
+		if attacker.Position.Y > windowHeight {
+			continue
+		}
+		attackers = append(attackers, attacker)
+	}
+	b.attackers = attackers
+}
+
+// lanHUD is the top of the HUD in a LAN game, with how the other player is doing
+// next to our own score, and anything to say along the bottom.
+func (g *Game) lanHUD() ([]hudItem, []string) {
+	p := g.player()
+	opponent := g.players[1-g.net.local]
+	grey := color.Gray{Y: 160}
+	items := []hudItem{
+		valueItem(tr("SCORE"), &g.hud.scores[g.current]),
+		{label: opponent.name, value: hudNumber(opponent.score), colour: grey},
+		{label: tr("THEIR LIVES"), value: strconv.Itoa(opponent.lives), colour: grey},
+		valueItem(tr("WAVE"), &g.hud.wave),
+		{label: tr("INCOMING"), value: strconv.Itoa(p.board.pendingAttackers + len(p.board.attackers)), colour: color.White},
+		{label: tr("THEIR ALIENS"), value: strconv.Itoa(opponent.board.aliveAliens()), colour: grey},
+	}
+	var lines []string
+	if g.net.stalled {
+		lines = append(lines, tr("WAITING FOR THE OTHER PLAYER"))
+	}
+	return items, lines
+}
+
+// drawLANResults is the results screen at the end of a LAN game or online co-op.
+func (g *Game) drawLANResults(screen *ebiten.Image) {
+	n := g.net
+	title := tr("DRAW")
+	switch {
+	case n.failure != "":
+		title = tr(n.failure) // In English, even from the other player, see tr
+	case g.mode == modeCoop:
+		title = tr("GAME OVER")
+	case n.winner == n.local:
+		title = tr("YOU WIN")
+	case n.winner >= 0:
+		title = tr("YOU LOSE")
+	}
+	lines := []string{title}
+	if len(g.players) == 2 && (g.mode == modeLAN || g.mode == modeCoop) {
+		for _, p := range g.players {
+			lines = append(lines, fmt.Sprintf("%s %s", p.name, number(p.score)))
+		}
+	}
+	if g.mode == modeCoop && n.failure == "" {
+		lines = append(lines, tr("Team %s", number(g.coopEntry().Score)))
+	}
+	lines = append(lines, "", tr("Press Esc to close the game"))
+
+	y := windowHeight / 3
+	for i, line := range lines {
+		face := g.gameFont
+		if i == 0 {
+			face = g.gameOverFont
+		}
+		bounds := text.BoundString(face, line)
+		text.Draw(screen, line, face, (windowWidth-bounds.Dx())/2, y, color.White)
+		y += text.BoundString(g.gameFont, "A").Dy() * 2
+		if i == 0 {
+			y += bounds.Dy()
+		}
+	}
+}
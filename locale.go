@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// Every piece of text on the screen goes through tr, which looks it up in the
+// message catalogue for the player's language, files/locales/<code>.json. Messages
+// are keyed by their English text, so anything not translated yet shows in English.
+const (
+	localesDir      = "files/locales"
+	defaultLanguage = "en"
+	languageSystem  = "system" // The language setting that follows the system's locale
+)
+
+// Locale is one language's message catalogue, loaded from files/locales.
+type Locale struct {
+	Code           string             `json:"-"`              // From the file name, e.g. ga for ga.json
+	Name           string             `json:"name"`           // In the language itself, for the settings panel
+	Font           string             `json:"font"`           // A font with the language's letters, if the game and Go fonts haven't got them
+	GroupSeparator string             `json:"groupSeparator"` // Between every three digits of a long number
+	Messages       map[string]message `json:"messages"`
+
+	font *truetype.Font
+}
+
+// message is a translation, with a form for each plural category it needs, see
+// pluralForm. One with no plurals is just a string in the file, its "other" form.
+type message map[string]string
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = message{"other": s}
+		return nil
+	}
+	forms := map[string]string{}
+	if err := json.Unmarshal(data, &forms); err != nil {
+		return err
+	}
+	if _, ok := forms["other"]; !ok {
+		return fmt.Errorf("plural message %v has no other form", forms)
+	}
+	*m = forms
+	return nil
+}
+
+var (
+	locales []*Locale // Every language that can be shown, English first
+	english *Locale
+	locale  *Locale // The language being shown, see applyLanguage
+)
+
+// goFont is the fallback for letters the game font hasn't got. It covers most
+// alphabets written with Latin, Greek or Cyrillic letters.
+var goFont = mustParseFont(goregular.TTF)
+
+func mustParseFont(ttf []byte) *truetype.Font {
+	parsed, err := truetype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// loadLocales loads every catalogue in files/locales. A language whose messages
+// have letters none of the fonts can draw isn't offered, as it would show gaps.
+func loadLocales() {
+	english = &Locale{Code: defaultLanguage, Name: "English"}
+	locales = []*Locale{english}
+	paths, _ := filepath.Glob(filepath.Join(localesDir, "*.json"))
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l := &Locale{Code: strings.TrimSuffix(filepath.Base(path), ".json")}
+		if err := json.Unmarshal(content, l); err != nil {
+			log.Fatal("Error reading ", path, ": ", err)
+		}
+		if l.Font != "" {
+			if ttf, err := ioutil.ReadFile(l.Font); err != nil {
+				log.Printf("Not offering %s: %v", l.Name, err)
+				continue
+			} else if l.font, err = truetype.Parse(ttf); err != nil {
+				log.Printf("Not offering %s: %s: %v", l.Name, l.Font, err)
+				continue
+			}
+		}
+		if missing := l.missingGlyphs(); missing != "" {
+			log.Printf("Not offering %s: no font has %q", l.Name, missing)
+			continue
+		}
+		if l.Code == defaultLanguage {
+			*english = *l // For its plural forms
+			continue
+		}
+		locales = append(locales, l)
+	}
+	applyLanguage()
+}
+
+// missingGlyphs is every letter in l's messages that neither the game font, l's
+// own font nor the Go font can draw.
+func (l *Locale) missingGlyphs() string {
+	var missing []rune
+	check := func(s string) {
+		for _, r := range s {
+			if unicode.IsControl(r) || strings.ContainsRune(string(missing), r) || gameTTF.Index(r) != 0 || goFont.Index(r) != 0 {
+				continue
+			}
+			if l.font == nil || l.font.Index(r) == 0 {
+				missing = append(missing, r)
+			}
+		}
+	}
+	check(l.Name)
+	check(l.GroupSeparator)
+	for _, m := range l.Messages {
+		for _, form := range m {
+			check(form)
+		}
+	}
+	return string(missing)
+}
+
+// applyLanguage shows the language setting's language, or if it isn't one that
+// can be shown, the system's, or failing that English.
+func applyLanguage() {
+	locale = english
+	for _, code := range []string{config.Language, systemLanguage()} {
+		if l := localeByCode(code); l != nil {
+			locale = l
+			return
+		}
+	}
+}
+
+func localeByCode(code string) *Locale {
+	for _, l := range locales {
+		if l.Code == code {
+			return l
+		}
+	}
+	return nil
+}
+
+// systemLanguage is the language of the system's locale, e.g. ga for ga_IE.UTF-8.
+func systemLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" || value == "C" || value == "POSIX" {
+			continue
+		}
+		code, _, _ := strings.Cut(value, ".")
+		code, _, _ = strings.Cut(code, "_")
+		code, _, _ = strings.Cut(code, "-")
+		return strings.ToLower(code)
+	}
+	return defaultLanguage
+}
+
+// languageOptions is what the language setting can be set to.
+func languageOptions() []string {
+	options := []string{languageSystem}
+	for _, l := range locales {
+		options = append(options, l.Code)
+	}
+	return options
+}
+
+func languageName() string {
+	if localeByCode(config.Language) == nil {
+		return tr("System  %s", locale.Name)
+	}
+	return locale.Name
+}
+
+// tr is key in the player's language, formatted with args like fmt.Sprintf. A
+// translation can put the args in another order with explicit indexes, e.g. %[2]s.
+// A message with plural forms picks one by the first int in args.
+func tr(key string, args ...any) string {
+	format := key
+	for _, l := range []*Locale{locale, english} {
+		if m, ok := l.Messages[key]; ok {
+			format = m[pluralForm(l.Code, pluralCount(args))]
+			if format == "" {
+				format = m["other"]
+			}
+			break
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func pluralCount(args []any) int {
+	for _, arg := range args {
+		if n, ok := arg.(int); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// pluralForm is the CLDR plural category n falls in for a language: one, two, few,
+// many or other. Languages not listed go by English's rule.
+func pluralForm(language string, n int) string {
+	switch language {
+	case "ga":
+		switch {
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n >= 3 && n <= 6:
+			return "few"
+		case n >= 7 && n <= 10:
+			return "many"
+		}
+		return "other"
+	case "ja", "ko", "zh":
+		return "other" // No plurals
+	}
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// number is n with the language's separator between every three digits, for
+// scores away from the HUD, which shows them plain like the cabinet.
+func number(n int) string {
+	magnitude := uint64(n)
+	if n < 0 {
+		magnitude = -magnitude // Unsigned, as -n overflows for the most negative int
+	}
+	digits := strconv.FormatUint(magnitude, 10)
+	if locale.GroupSeparator != "" {
+		for i := len(digits) - 3; i > 0; i -= 3 {
+			digits = digits[:i] + locale.GroupSeparator + digits[i:]
+		}
+	}
+	if n < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// textFace is the game font at one size. Whatever the game font hasn't got, such
+// as accents or another alphabet, is drawn at the same size in the language's own
+// font if it has one, or otherwise the Go font.
+type textFace struct {
+	font.Face
+	size      float64
+	fallbacks map[*truetype.Font]font.Face
+}
+
+// gameTTF is the game font, see loadFont.
+var gameTTF *truetype.Font
+
+func newTextFace(ttf *truetype.Font, size float64) *textFace {
+	return &textFace{
+		Face:      truetype.NewFace(ttf, &truetype.Options{Size: size, DPI: 72}),
+		size:      size,
+		fallbacks: map[*truetype.Font]font.Face{},
+	}
+}
+
+// faceFor is the face r is drawn with.
+func (f *textFace) faceFor(r rune) font.Face {
+	if gameTTF.Index(r) != 0 {
+		return f.Face
+	}
+	for _, ttf := range []*truetype.Font{locale.font, goFont} {
+		if ttf == nil || ttf.Index(r) == 0 {
+			continue
+		}
+		face, ok := f.fallbacks[ttf]
+		if !ok {
+			face = truetype.NewFace(ttf, &truetype.Options{Size: f.size, DPI: 72})
+			f.fallbacks[ttf] = face
+		}
+		return face
+	}
+	return f.Face
+}
+
+func (f *textFace) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	return f.faceFor(r).Glyph(dot, r)
+}
+
+func (f *textFace) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	return f.faceFor(r).GlyphBounds(r)
+}
+
+func (f *textFace) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	return f.faceFor(r).GlyphAdvance(r)
+}
+
+// Kern only kerns two letters from the same font.
+func (f *textFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if face := f.faceFor(r0); face == f.faceFor(r1) {
+		return face.Kern(r0, r1)
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestShippedLocales checks every catalogue in files/locales is offered, so none
+// is missing its font or has letters no font can draw.
+func TestShippedLocales(t *testing.T) {
+	newTestGame(t)
+	paths, err := filepath.Glob(filepath.Join(localesDir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("No catalogues in %s: %v", localesDir, err)
+	}
+	for _, path := range paths {
+		code := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(code, func(t *testing.T) {
+			l := localeByCode(code)
+			if l == nil {
+				t.Fatalf("%s isn't offered", path)
+			}
+			if missing := l.missingGlyphs(); missing != "" {
+				t.Errorf("No font has %q", missing)
+			}
+		})
+	}
+}
+
+func TestPluralForm(t *testing.T) {
+	tests := []struct {
+		language string
+		n        int
+		want     string
+	}{
+		{"en", 0, "other"},
+		{"en", 1, "one"},
+		{"en", 2, "other"},
+		{"en", -1, "other"},
+		{"fr", 1, "one"}, // Not listed, so English's rule
+		{"ga", 0, "other"},
+		{"ga", 1, "one"},
+		{"ga", 2, "two"},
+		{"ga", 3, "few"},
+		{"ga", 6, "few"},
+		{"ga", 7, "many"},
+		{"ga", 10, "many"},
+		{"ga", 11, "other"},
+		{"ga", 101, "other"},
+		{"ja", 1, "other"},
+		{"ja", 2, "other"},
+	}
+	for _, test := range tests {
+		if got := pluralForm(test.language, test.n); got != test.want {
+			t.Errorf("pluralForm(%q, %d) = %q, want %q", test.language, test.n, got, test.want)
+		}
+	}
+}
+
+func TestTr(t *testing.T) {
+	newTestGame(t)
+	saved := locale
+	defer func() { locale = saved }()
+
+	tests := []struct {
+		language string
+		key      string
+		args     []any
+		want     string
+	}{
+		{"en", "%d POINTS", []any{1}, "1 POINT"},
+		{"en", "%d POINTS", []any{0}, "0 POINTS"},
+		{"en", "%d POINTS", []any{25}, "25 POINTS"},
+		{"ga", "%d POINTS", []any{1}, "1 PHOINTE"},
+		{"ga", "%d POINTS", []any{2}, "2 PHOINTE"},
+		{"ga", "%d POINTS", []any{8}, "8 bPOINTE"},
+		{"ga", "%d POINTS", []any{20}, "20 POINTE"},
+		{"ga", "SETTINGS", nil, "SOCRUITHE"},
+		{"ga", "%s is used by the menus", []any{"Up"}, "Úsáideann na roghchláir Up"},
+		{"ja", "SETTINGS", nil, "設定"},
+		{"ja", "%d POINTS", []any{1}, "1 ポイント"},
+		{"ja", "%d POINTS", []any{2}, "2 ポイント"},
+		{"ga", "Not a message %d", []any{3}, "Not a message 3"},
+		{"fr", "%d credits", []any{1}, "1 credit"}, // Nothing translated, so English's plurals
+		{"fr", "%d credits", []any{2}, "2 credits"},
+		{"ga", "Not a message", nil, "Not a message"},
+	}
+	for _, test := range tests {
+		locale = localeByCode(test.language)
+		if locale == nil {
+			locale = &Locale{Code: test.language}
+		}
+		if got := tr(test.key, test.args...); got != test.want {
+			t.Errorf("In %s, tr(%q, %v) = %q, want %q", test.language, test.key, test.args, got, test.want)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	newTestGame(t)
+	saved := locale
+	defer func() { locale = saved }()
+
+	tests := []struct {
+		separator string
+		n         int
+		want      string
+	}{
+		{",", 0, "0"},
+		{",", 999, "999"},
+		{",", 1000, "1,000"},
+		{",", 1234567, "1,234,567"},
+		{",", -1234, "-1,234"},
+		{",", -999, "-999"},
+		{" ", 100000, "100 000"},
+		{"", 1234567, "1234567"},
+		{",", math.MaxInt64, "9,223,372,036,854,775,807"},
+		{",", math.MinInt64, "-9,223,372,036,854,775,808"},
+	}
+	for _, test := range tests {
+		locale = &Locale{GroupSeparator: test.separator}
+		if got := number(test.n); got != test.want {
+			t.Errorf("number(%d) with %q = %q, want %q", test.n, test.separator, got, test.want)
+		}
+	}
+}
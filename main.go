@@ -19,33 +19,174 @@ package main
     - bombSpeed: Change the speed at which bombs fall.
     - barrierYPosition: Adjust the vertical position of the barriers.
     - playerYPosition: Set the initial vertical position of the player's cannon.
+    - defaultHandling (cannon.go): Acceleration, friction and top speed of the cannon,
+      all in pixels per second so the feel is the same at any tick rate.
 
-    Audio Settings:
+    Rulesets:
 
-    - You can adjust the volume of each sound effect by modifying the volume
-      when creating the audio player in the loadAudio function.
-      Example:
-          audioStream, err := audioContext.NewPlayer(wavStream)
-          audioStream.SetVolume(0.5) // Sets volume to 50%
-      The volume parameter is a float64 between 0.0 (silent) and 1.0 (full volume).
+    - The settings above are the "relaxed" rules. The "classic" rules reproduce the
+      1978 cabinet (224x256 screen, march speed and column firing tables, UFO,
+      extra life at 1500 points) and are defined in rules.go.
+    - Pick the rules with "rules" in the config file (config.json in your user
+      config folder, e.g. ~/.config/invaders on Linux) or for one game with:
+          go run . -rules classic
 
-    Control Settings:
+    LAN Games:
 
-    - The game controls are currently hardcoded in the Update() function.
-    - To change the controls, modify the ebiten.IsKeyPressed() and
-      inpututil.IsKeyJustPressed() functions within Update().
-      For example, to change the key for moving the cannon to the right:
-          if ebiten.IsKeyPressed(ebiten.KeyD) { // Change from KeyArrowRight to KeyD
-              laserCannon.Position.X += 10
-          }
+    - Host a head-to-head game with "go run . -host :7777" and join it from another
+      computer with "go run . -join <host address>:7777". The protocol is in netplay.go
+      and the LAN rules (attackers sent to the other player) are in lan.go.
+    - Add -coop when hosting to play online co-op instead. It rolls back rather than
+      waiting for the other player, see rollback.go. -input-delay sets the frames of
+      delay, and -fake-latency 80ms -fake-jitter 30ms -fake-loss 0.1 pretend the
+      network is slow and loses packets. Checksums of the world go with the inputs
+      until the other game has compared them, so a lost packet can't skip a check.
+
+    Replays:
+
+    - Every local game is saved as files/lastrun.json when it ends: the seed, the
+      settings, each tick's input and the shop purchases (run.go). Play it back with
+      "go run . -replay files/lastrun.json". A replay that scores differently from
+      the game it was recorded from says so. Versus isn't recorded.
+
+    Spectators:
 
-    Adding a Settings Panel:
+    - Add -spectate-server :8080 to stream the game, and watch it from another computer
+      with "go run . -spectate ws://<address>:8080". See spectate.go.
 
-    - To add a settings panel that can be opened/closed with a button:
-      1. Create a new struct for managing settings.
-      2. Implement drawing functions for the panel.
-      3. Add a button to toggle the panel in the main game's Draw() function.
-      4. Handle input for the settings panel in the Update() function.
+    Audio Settings:
+
+    - The music and sound effects volumes are settings (see Settings below), saved
+      in the config file. loadAudio loads each sound at full volume and applyVolumes
+      turns them down to the settings once they are all loaded, and again whenever a
+      slider changes. A volume is a float64 between 0.0 (silent) and 1.0 (full volume).
+
+    Control Settings:
+
+    - Move left, move right, fire, pause, quit game and confirm are actions bound to
+      keys (input.go). Press K on the ship select screen or while paused to change
+      them. They are saved as "keys" in the config file, e.g.
+          "keys": {"fire": "Space", "moveLeft": "Z", "moveRight": "X"}
+    - The split keyboard for local co-op and the versus invader's keys are fixed,
+      see coopControls and invaderKeys. They and every other key the game reads
+      itself are in reservedKeys, which bindingConflict refuses.
+    - Gamepads with a standard layout work too (gamepad.go): d-pad or left stick to
+      move, A to fire, Start to pause or confirm. Each player gets their own gamepad
+      in the order they are plugged in. The stick dead zone is on the controls screen.
+    - The control scheme on the controls screen adds the mouse or a touch screen
+      (pointer.go). With the mouse the cannon chases the pointer at the ship's top
+      speed and left click fires. Touch draws Left, Right and Fire buttons over the
+      game. Both go through logicalPosition (display.go), so they line up whatever
+      the window size and scaling.
+
+    Accessibility:
+
+    - The settings panel also has settings for players who can only use one button
+      (assist.go). In the "sweep" one switch mode the cannon sweeps back and forth by
+      itself and fire turns it round. In "track" it follows a column of aliens and fire
+      moves it on to the next column. The cannon fires by itself in both.
+    - Game speed slows everything down by running fewer ticks a second, and No death
+      stops bombs and the invasion costing lives. None of them work in network games.
+    - A game played with any of them on says Assisted in the HUD, and its score is
+      marked Assisted on the leaderboard (an extra ",assisted" column in the file).
+
+    Scenes:
+
+    - Each screen is a scene with its own update and draw (scene.go): Title,
+      ModeSelect, NameEntry, Playing, Paused, WaveClear, GameOver, Settings,
+      Controls and Spectating. They sit on a stack; Paused, Settings, Controls and
+      WaveClear go on top of the scene they were opened from, everything else
+      replaces the whole stack.
+    - Every change fades out to black and back in (fadeTicks). A scene's enter and
+      exit are where it starts and stops things, e.g. Paused stops the music.
+    - However a game ends, recordScores asks for a name for each score good enough
+      for the leaderboard (HighScoreEntry, highscores.go) before going on. Names
+      can be typed in any alphabet, or picked a letter at a time on the letter
+      wheel with a gamepad. The login name is only the starting point.
+    - The high score files are CSV, so a name with a comma in it is quoted rather
+      than breaking the file. Letters the game font can't draw use the Go font.
+    - Left alone, the title screen turns through the start picture, the points
+      table and the high scores (titlePageTicks each), then a bot plays a silent
+      demo game (attract.go). Any key, button or touch goes back to the title.
+
+    HUD:
+
+    - The HUD (hud.go) is drawn in the game font at HUDFontSize, a size for each
+      ruleset. Along the top are the scores, the high score, the wave and the rest,
+      spread evenly across the screen; items that don't fit are left off from the
+      end. Labels go above the numbers when there's room above the formation.
+    - Each player's lives (a number and a row of their cannon) and power-ups go in
+      the bottom corners. Anything else, like Assisted, goes along the bottom.
+    - Numbers are hudValues, updated every tick in updateHUD: they roll up to a
+      new value (hudRollDivisor) and flash when they change (hudFlashTicks).
+
+    Settings Panel:
+
+    - O on the title screen or Settings on the pause menu opens the settings panel
+      (settings.go): music and sound effects volume, difficulty, fullscreen, window
+      scale, scaling, language, palette, background, reduced motion, the controls
+      screen and the accessibility settings. Each row is a setting with a value, and a level if it
+      draws as a slider.
+    - Changes take effect straight away (applyVolumes, applyDisplay) and are saved
+      to the config file, which main loads before anything else. configVersion is
+      bumped whenever its layout changes, and migrateConfig brings older files up
+      to date. A file from a newer version stops the game rather than lose settings.
+    - Difficulty scales the rules' bomb chance, bomb speed and classic reload time
+      (Game.difficulty). Network games always play on normal.
+
+    Display:
+
+    - Everything is drawn at the rules' logical resolution, which Layout always
+      returns. DrawFinalScreen (display.go) scales it up to the window, keeping its
+      shape, with black bars to fill the rest. The Scaling setting picks "fit", as
+      big as fits, or "integer", the biggest whole number of times, for even pixels.
+    - F11 or Alt+Enter goes in and out of fullscreen on any screen. The window can
+      be resized by dragging; once it stops changing size for half a second that
+      size is saved in the config file and the game opens at it next time.
+    - Backgrounds are drawn with drawBackdrop, which keeps their shape by cropping
+      the edges instead of stretching them.
+
+    Languages:
+
+    - All the text on screen goes through tr (locale.go), which looks it up in the
+      catalogue for the Language setting, files/locales/<code>.json, or if that is
+      "system", the system's locale from LC_ALL, LC_MESSAGES or LANG. Messages are
+      keyed by their English text with fmt verbs, so anything missing shows in English.
+    - A message can have plural forms (one, two, few, many, other), picked by the
+      first int it's given with the language's CLDR rule (pluralForm). number puts
+      the language's separator into long numbers away from the HUD.
+    - The game font only has letters and digits, so loadFont's faces draw anything
+      else in the language's own font (the catalogue's "font") or the Go font, at
+      the same size. Japanese has font/ja.ttf, the M+ 1p font. A language with
+      letters none of them can draw isn't offered, and a test checks every shipped
+      catalogue can be.
+    - Names, ship and upgrade names and network messages stay as they are; failure
+      and spectator status strings go over the network in English and are
+      translated where they're shown.
+
+    Palettes:
+
+    - The Palette setting (palette.go) recolours the play field and HUD for colour
+      blindness (deuteranopia, protanopia, tritanopia), in pure bright colours (high
+      contrast), or in white under the cabinet's red and green strips.
+    - Sprites are drawn with drawSprite and a spriteRole, which turns every pixel of
+      a palette's sprite to its role's colour with a colour matrix; bombs and beams
+      get a black outline. Players' and HUD colours go through paletteColour at draw
+      time, so p.colour stays the player's own colour over the network.
+    - The Background setting dims the background picture (backgroundDimming) or
+      leaves it out, see drawBackdrop.
+
+    Reduced Motion:
+
+    - Everything that flashes, blinks or animates for show asks the effects budget
+      (effects.go) how to look, so the Reduced motion setting is enforced in one place.
+    - With it on, the screen has at most maxFlashesPerSecond flashes a second, and a
+      HUD number stays lit for its flash instead of flickering (numbers that change
+      on the same tick flash together, as one, see updateHUD); the game over text
+      stops blinking; explosions fade out from half brightness; and the aliens change
+      frame every safeAnimationTicks instead of every tick.
+    - The budget only changes drawing, not the game, so network games and rollbacks
+      play the same with it on or off.
 
     Using a Switch Statement (Illustrative Example):
 
@@ -80,14 +221,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
+	"math/rand/v2"
 	"os"
 	"os/user"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -128,8 +273,6 @@ var (
 	src           *ebiten.Image
 	background    *ebiten.Image
 	backgroundEnd *ebiten.Image
-	cannonSprite  = image.Rect(20, 47, 38, 59)
-	cannonExplode = image.Rect(0, 47, 16, 57)
 	alien1Sprite  = image.Rect(0, 0, 20, 14)
 	alien1aSprite = image.Rect(20, 0, 40, 14)
 	alien2Sprite  = image.Rect(0, 14, 20, 26)
@@ -150,16 +293,9 @@ type Sprite struct {
 	Position image.Point
 	Status   bool
 	Points   int
+	hitbox   image.Rectangle // Optional, relative to Position; size is used when empty
 }
 
-var (
-	aliens      = []Sprite{}
-	bombs       = []Sprite{}
-	barriers    = []Sprite{}
-	laserCannon Sprite
-	beam        Sprite
-)
-
 var (
 	laserSound         *audio.Player
 	explosionSound     *audio.Player
@@ -177,23 +313,31 @@ var (
 )
 
 var (
-	highScores []HighScore
-	playerName string
+	highScores     []HighScore
+	coopHighScores []HighScore // Co-op teams, with both names and their combined score
+	playerName     string      // The login name, until a player types their own
 )
 
-const maxHighScores = 5
+const (
+	maxHighScores      = 5
+	highScoresPath     = "files/highscores.txt"
+	coopHighScoresPath = "files/highscores-coop.txt"
+)
 
 type HighScore struct {
-	Name  string
-	Score int
+	Name     string
+	Score    int
+	Ship     string
+	Rules    string // Scoring rules the score was set under, see scoring.go
+	Assisted bool   // Played with an accessibility setting on, see assist.go
 }
 
 func createAlien(x, y int, sprite, alt image.Rectangle, points int) (s Sprite) {
 	s = Sprite{
-		size:     sprite,
-		Filter:   src.SubImage(sprite).(*ebiten.Image),
-		FilterA:  src.SubImage(alt).(*ebiten.Image),
-		FilterE:  src.SubImage(alienExplode).(*ebiten.Image),
+		size:     sheetRect(sprite),
+		Filter:   src.SubImage(sheetRect(sprite)).(*ebiten.Image),
+		FilterA:  src.SubImage(sheetRect(alt)).(*ebiten.Image),
+		FilterE:  src.SubImage(sheetRect(alienExplode)).(*ebiten.Image),
 		Position: image.Pt(x, y),
 		Status:   true,
 		Points:   points,
@@ -210,10 +354,8 @@ func loadFont(path string, size float64) font.Face {
 	if err != nil {
 		log.Fatal(err)
 	}
-	return truetype.NewFace(ttfFont, &truetype.Options{
-		Size: size,
-		DPI:  72,
-	})
+	gameTTF = ttfFont
+	return newTextFace(ttfFont, size) // Falls back to other fonts for other languages, see locale.go
 }
 func loadAudio(path string) *audio.Player {
 	fileBytes, err := ioutil.ReadFile(path)
@@ -244,14 +386,12 @@ func loadAudio(path string) *audio.Player {
 
 	}
 
-	audioStream.SetVolume(0.5)
-
-	return audioStream
+	return audioStream // At full volume until applyVolumes
 }
 func createBarrier(x, y int) (s Sprite) {
 	s = Sprite{
-		size:     barrierSprite,
-		Filter:   src.SubImage(barrierSprite).(*ebiten.Image),
+		size:     sheetRect(barrierSprite),
+		Filter:   src.SubImage(sheetRect(barrierSprite)).(*ebiten.Image),
 		Position: image.Pt(x, y),
 		Status:   true,
 	}
@@ -263,7 +403,7 @@ func initGame() {
 	if err != nil {
 		panic(err)
 	}
-	src = imgFile
+	src = scaleSheet(imgFile) // Classic rules use the sheet at single pixel size
 
 	bg, _, err := ebitenutil.NewImageFromFile("imgs/bg.png")
 	if err != nil {
@@ -277,44 +417,13 @@ func initGame() {
 	}
 	backgroundEnd = bgEnd
 
-	laserCannon = Sprite{
-		size:     cannonSprite,
-		Filter:   src.SubImage(cannonSprite).(*ebiten.Image),
-		FilterE:  src.SubImage(cannonExplode).(*ebiten.Image),
-		Position: image.Pt(50, playerYPosition),
-		Status:   true,
+	// The cannon's sprites come from the selected ship, see applyShip
+	loadShips("files/ships.json")
+	loadUpgrades("files/upgrades.json")
+	if ruleset.Ship != nil {
+		projectiles[ruleset.Ship.Projectile] = ruleset.Projectile
 	}
-
-	beam = Sprite{
-		size:     beamSprite,
-		Filter:   src.SubImage(beamSprite).(*ebiten.Image),
-		Position: image.Pt(laserCannon.Position.X+7, 250),
-		Status:   false,
-	}
-
-	rows := 5
-	cols := 12
-	for row := 0; row < rows; row++ {
-		for col := 0; col < cols; col++ {
-			x := aliensStartCol + col*(alienSize+10)
-			y := 30 + row*30
-			points := 10
-			if row == 0 {
-				points = 30
-				aliens = append(aliens, createAlien(x, y, alien1Sprite, alien1aSprite, points))
-			} else if row == 1 || row == 2 {
-				points = 20
-				aliens = append(aliens, createAlien(x, y, alien2Sprite, alien2aSprite, points))
-			} else if row == 3 || row == 4 {
-				points = 10
-				aliens = append(aliens, createAlien(x, y, alien3Sprite, alien3aSprite, points))
-			}
-		}
-	}
-	barrierWidth := (windowWidth - 100) / 4
-	barriers = append(barriers, createBarrier(100, barrierYPosition))
-	barriers = append(barriers, createBarrier(100+barrierWidth, barrierYPosition))
-	barriers = append(barriers, createBarrier(100+2*barrierWidth, barrierYPosition))
+	ufo = createUFO()
 
 	gameFont = loadFont("font/font.ttf", 24)
 	gameOverFont = loadFont("font/font.ttf", 56)
@@ -330,17 +439,25 @@ func initGame() {
 }
 
 func loadHighScores() {
-	highScores = []HighScore{}
-	content, err := ioutil.ReadFile("files/highscores.txt")
-	if err == nil {
-		lines := strings.Split(string(content), "\n")
+	highScores = readHighScores(highScoresPath)
+	coopHighScores = readHighScores(coopHighScoresPath)
+}
 
-		for _, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				continue
+func readHighScores(path string) []HighScore {
+	scores := []HighScore{}
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.FieldsPerRecord = -1 // Older files don't have the last columns
+		reader.LazyQuotes = true
+
+		for {
+			// name,score,ship,rules,assisted - names with commas or quotes in are quoted
+			parts, err := reader.Read()
+			if err == io.EOF {
+				break
 			}
-			parts := strings.Split(line, ",")
-			if len(parts) != 2 {
+			if err != nil || len(parts) < 2 || len(parts) > 5 {
 				continue
 			}
 			name := parts[0]
@@ -348,46 +465,63 @@ func loadHighScores() {
 			if err != nil {
 				continue
 			}
-			highScores = append(highScores, HighScore{Name: name, Score: score})
+			ship := ""
+			if len(parts) >= 3 {
+				ship = parts[2]
+			}
+			rules := scoringStandard
+			if len(parts) >= 4 {
+				rules = parts[3]
+			}
+			assisted := len(parts) == 5 && parts[4] == "assisted"
+			scores = append(scores, HighScore{Name: name, Score: score, Ship: ship, Rules: rules, Assisted: assisted})
 		}
 	}
-	sortHighScores()
+	return sortHighScores(scores)
 }
 
-func saveHighScores() {
+// saveHighScores writes the scores to path as CSV, so a name with a comma,
+// a quote or anything else in it comes back the same.
+func saveHighScores(path string, scores []HighScore) {
 	var sb strings.Builder
-	for _, score := range highScores {
-		sb.WriteString(score.Name)
-		sb.WriteString(",")
-		sb.WriteString(strconv.Itoa(score.Score))
-		sb.WriteString("\n")
+	writer := csv.NewWriter(&sb)
+	for _, score := range scores {
+		record := []string{score.Name, strconv.Itoa(score.Score), score.Ship, score.Rules}
+		if score.Assisted {
+			record = append(record, "assisted")
+		}
+		writer.Write(record)
 	}
-	ioutil.WriteFile("files/highscores.txt", []byte(sb.String()), 0644)
+	writer.Flush()
+	ioutil.WriteFile(path, []byte(sb.String()), 0644)
 }
-func sortHighScores() {
-	sort.Slice(highScores, func(i, j int) bool {
-		return highScores[i].Score > highScores[j].Score
+func sortHighScores(scores []HighScore) []HighScore {
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
 	})
-	if len(highScores) > maxHighScores {
-		highScores = highScores[:maxHighScores]
+	if len(scores) > maxHighScores {
+		scores = scores[:maxHighScores]
 	}
+	return scores
 }
 
-func addHighScore(score int) {
-	if len(highScores) == maxHighScores && score <= highScores[maxHighScores-1].Score {
+// addHighScore puts the entry on a leaderboard if it is good enough and saves the board to path.
+func addHighScore(scores *[]HighScore, path string, entry HighScore) {
+	if !qualifies(*scores, entry.Score) {
 		return
 	}
 
-	for _, existingScore := range highScores {
-		if existingScore.Name == playerName && existingScore.Score == score {
+	for _, existingScore := range *scores {
+		if existingScore.Name == entry.Name && existingScore.Score == entry.Score {
 			return
 		}
 	}
 
-	highScores = append(highScores, HighScore{Name: playerName, Score: score})
-
-	sortHighScores()
-	saveHighScores()
+	if entry.Name == "" {
+		entry.Name = playerName
+	}
+	*scores = sortHighScores(append(*scores, entry))
+	saveHighScores(path, *scores)
 }
 
 //   This is the end of Part 1
@@ -402,91 +536,180 @@ func addHighScore(score int) {
 
 type Game struct { // Main Game struct — add fields here!
 	loop             int
-	beamShot         bool
 	gameOver         bool
-	alienDirection   int
-	score            int
-	startScreen      *ebiten.Image
+	startScreen      *ebiten.Image // Title screen picture, imgs/start.png
 	gameFont         font.Face
 	gameOverFont     font.Face
-	lives            int
-	isPaused         bool
+	hudFont          font.Face // The game font at the HUD's size, see hud.go
+	hud              hudState
 	gameOverTimer    int
 	showGameOverText bool // Fields correctly placed in the main Game struct
+	handling         Handling
+	shipIndex        int          // Highlighted ship on the select screen
+	ship             Ship         // Ship being flown, see applyShip
+	shopIndex        int          // Highlighted upgrade in the shop
+	scoring          string       // scoringStandard or scoringCombo
+	popups           []scorePopup // Floating scores
+	prompt           int          // Screen shown between turns and before a network game, see updatePrompt
+	promptTimer      int
+	players          []*Player // Score, lives and board of each player
+	current          int       // Index of the player whose turn it is
+	mode             int       // modeSolo, modeAlternating or modeCoop, chosen on the ship select screen
+	naming           int       // Player typing their name
+	nameInput        []rune
+	wheelIndex       int            // Letter picked on the name entry letter wheel
+	pendingScores    []pendingScore // High scores still to be named, see highScoreEntryScene
+	ufoTimer         int            // Ticks until the next UFO
+	ufoDirection     int
+	ufoScore         int // Value of the last UFO hit, shown where it was
+	ufoScoreX        int
+	ufoScoreTicks    int
+	versus           Versus      // Rounds and the invader player's budget, in versus
+	rng              *rand.Rand  // All the simulation's random numbers, see seedRandom
+	pcg              *rand.PCG   // Source behind rng, saved in rollback snapshots
+	net              *netSession // Connection to the other player in a network game
+	resimulating     bool        // Replaying frames after a rollback, so no sounds or popups
+	demo             bool        // The attract mode demo is playing, silently, see attract.go
+	run              *Run        // The local game being played, recorded to replay, see run.go
+	replay           *replay     // The run being played back, silently, see replayScene
+	demoTimer        int
+	titleTimer       int      // Ticks on the title screen without anyone touching the controls
+	pauseIndex       int      // Highlighted choice on the pause menu
+	confirmingQuit   bool     // The pause menu is asking whether to quit to the title
+	settingsIndex    int      // Highlighted row on the settings panel
+	assisted         bool     // An accessibility setting has helped this game, see assistActive
+	bindingIndex     int      // Action picked on the key binding screen
+	capturingKey     bool     // Waiting for the new key for the picked action
+	bindingMessage   string   // Why the last key pressed couldn't be used
+	scenes           []scene  // Screens on top of each other, the top one showing, see scene.go
+	fadeTimer        int      // Ticks left of the fade between scenes
+	fadeChanges      []func() // Changes to the scenes to make once the screen is black
+	notice           string   // Shown along the bottom of the screen, e.g. a gamepad plugged in
+	noticeTimer      int
+	windowSize       image.Point // Window size last tick, to notice it being dragged
+	windowSettle     int         // Ticks until a dragged window's size is remembered
+
+	spectators          *spectateServer  // Streaming to spectators, started with -spectate-server
+	spectateSent        time.Time        // When the world was last captured for the stream
+	spectator           *spectatorClient // Only watching another game, started with -spectate
+	spectatorStatusText string           // What the watched players are doing, from the stream
 }
 
 func (g *Game) Update() error { // Correct Update function – no local Game struct
-	if g.gameOver {
-		g.gameOverTimer++ // Now refers to g.gameOverTimer of the *main* Game struct
-		if g.gameOverTimer%60 == 0 {
-			g.showGameOverText = !g.showGameOverText
+	g.updateGamepads()
+	g.applyGameSpeed()
+	if g.spectators != nil {
+		g.updateSpectators()
+	}
+	g.watchWindow()
+	if !g.updateFullscreen() {
+		g.updateScenes()
+	}
+	g.updateHUD()
+	effects.update()
+	return nil
+}
+
+// updatePlaying runs one tick of the game, see playingScene.
+func (g *Game) updatePlaying() {
+	// Online co-op can end on a guessed frame, so it plays on until a rollback can't bring the game back
+	if g.gameOver && (g.net == nil || g.net.settled()) {
+		if g.net == nil && g.run != nil {
+			g.saveRun(lastRunPath)
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-			g.isPaused = false
-			g.resetGame()
-			return nil
+		g.recordScores(gameOverScene{})
+		return
+	}
+
+	if g.prompt != promptNone {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			g.quit()
 		}
+		g.updatePrompt()
+		return
+	}
+
+	if g.net != nil {
+		// A network game can't be paused, the other player is still playing
 		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-			os.Exit(0)
+			g.quit()
+		}
+		if g.net.rollback {
+			g.updateRollback()
+		} else {
+			g.updateNet()
 		}
-		return nil
+		return
 	}
 
-	if g.isPaused {
-		return nil
+	if g.gameOver {
+		return
+	}
+	if justPressed(actionQuit) {
+		g.gameOver = true // The game over screen records the scores so far
+		return
+	}
+	if justPressed(actionPause) {
+		g.pushScene(pausedScene{})
+		return
 	}
 
-	if !g.gameOver {
-		if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-			laserCannon.Position.X += 10
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-			laserCannon.Position.X -= 10
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyDown) {
-			playerYPosition = min(windowHeight-50, playerYPosition+5)
-			for i := range barriers {
-				barriers[i].Position.Y = barrierYPosition + 5
-			}
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyUp) {
-			playerYPosition = max(100, playerYPosition-5)
-			for i := range barriers {
-				barriers[i].Position.Y = barrierYPosition - 5
-			}
-		}
+	var inputs [2]Input
+	for _, p := range g.activePlayers() {
+		inputs[slices.Index(g.players, p)] = g.readInput(p)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		inputs[0] |= runLower
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyUp) {
+		inputs[0] |= runRaise
+	}
+	if g.run != nil {
+		g.run.record(inputs)
+	}
+	g.playTick(inputs)
+}
 
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-			if !beam.Status {
-				g.beamShot = true
-				if laserSound != nil {
-					laserSound.Rewind()
-					laserSound.Play()
-				}
-			}
-		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
-			g.gameOver = true
-			g.isPaused = true
-		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-			g.isPaused = !g.isPaused
+// playTick plays one tick of a local game with each player's input, by player
+// number, read from the controls or from a run being replayed.
+func (g *Game) playTick(inputs [2]Input) {
+	for _, p := range g.activePlayers() {
+		g.applyInput(p, inputs[slices.Index(g.players, p)])
+	}
+	barriers := g.board().barriers
+	if inputs[0]&runLower != 0 {
+		playerYPosition = min(windowHeight-50, playerYPosition+5)
+		for i := range barriers {
+			barriers[i].Position.Y = barrierYPosition + 5
 		}
-
-		if g.beamShot {
-			beam.Position.X = laserCannon.Position.X + 7
-			beam.Status = true
-			g.beamShot = false
+	}
+	if inputs[0]&runRaise != 0 {
+		playerYPosition = max(100, playerYPosition-5)
+		for i := range barriers {
+			barriers[i].Position.Y = barrierYPosition - 5
 		}
 	}
+	g.step()
+}
 
-	if aliens[0].Position.X < alienSize || aliens[aliensPerRow-1].Position.X > windowWidth-(2*alienSize) {
-		g.alienDirection = g.alienDirection * -1
-		for i := 0; i < len(aliens); i++ {
-			aliens[i].Position.Y = aliens[i].Position.Y + 10
-		}
+// updateGameOver waits on the game over screen for the players to play again, see gameOverScene.
+func (g *Game) updateGameOver() {
+	g.gameOverTimer++ // Now refers to g.gameOverTimer of the *main* Game struct
+	g.showGameOverText = effects.blink(g.gameOverTimer, 60)
+	if gameOverSound != nil && !gameOverSound.IsPlaying() {
+		gameOverSound.Rewind()
+		gameOverSound.Play()
+	}
+	if g.net != nil {
+		g.net.sendInputs() // Until the other game has every frame it needs to finish too
+	}
+	if (justPressed(actionConfirm) || justPressed(actionFire)) && g.net == nil {
+		g.newGame()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.quit()
 	}
-	return nil
 }
 
 // Part 2: Game Rendering and Logic
@@ -494,28 +717,15 @@ func (g *Game) Update() error { // Correct Update function – no local Game str
 func (g *Game) drawGameOverScreen(screen *ebiten.Image) {
 	// Check if backgroundEnd is loaded
 	if backgroundEnd != nil {
-		// Calculate scale factors for the game over background
-		bgWidth, bgHeight := backgroundEnd.Bounds().Dx(), backgroundEnd.Bounds().Dy()
-		xScale := float64(windowWidth) / float64(bgWidth)
-		yScale := float64(windowHeight) / float64(bgHeight)
-		// Draw the game over background image with scaling
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Scale(xScale, yScale)
-		screen.DrawImage(backgroundEnd, op) // Draw the image
+		drawBackdrop(screen, backgroundEnd) // Scaled to cover the screen
 	} else {
 		// If backgroundEnd is not loaded, use a default black background
 		// screen.Fill(color.Black) // Background colour, change to modify - Remove the // at the start of this line to have a black background and no image.
 	}
 
-	// Play game over sound
-	if gameOverSound != nil && !gameOverSound.IsPlaying() {
-		gameOverSound.Rewind()
-		gameOverSound.Play()
-	}
-
 	// Define box parameters (adjust these as needed)
-	boxWidth := 400
-	boxHeight := 500                       // Increased height to make room for scores
+	boxWidth := min(400, windowWidth)
+	boxHeight := min(500, windowHeight)    // Increased height to make room for scores
 	boxX := (windowWidth - boxWidth) / 2   // Center the box horizontally
 	boxY := (windowHeight - boxHeight) / 4 // Center vertically, adjust as needed
 
@@ -524,45 +734,68 @@ func (g *Game) drawGameOverScreen(screen *ebiten.Image) {
 
 	// Define the message and the "Try Again" button text
 
+	if g.net != nil {
+		g.drawLANResults(screen)
+		return
+	}
+	if g.showGameOverText && g.mode == modeVersus {
+		g.drawVersusResults(screen)
+		return
+	}
+
 	if g.showGameOverText { // Draw text conditionally
 		// Define the message and the "Try Again" button text
 
-		message := fmt.Sprintf("GAME OVER!\n\nFinal score: %d", g.score)
-		tryAgain := "Press Enter to Play again"
-		closeGame := "Press Esc to close the game"
+		message := tr("GAME OVER!\n\nFinal score: %s", number(g.player().score))
+		if len(g.players) > 1 {
+			message = tr("GAME OVER") + "\n\n" + g.playerScores()
+		}
+		scores, scoresTitle := highScores, tr("High Scores:")
+		if g.mode == modeCoop {
+			message += "\n" + tr("Team %s", number(g.coopEntry().Score))
+			scores, scoresTitle = coopHighScores, tr("Co op High Scores")
+		}
+		tryAgain := tr("Press %s to Play again", keyName(actionConfirm))
+		closeGame := tr("Press Esc to close the game")
 
 		// Get text bounds to calculate the center position
 		messageBounds := text.BoundString(g.gameOverFont, message)
 		tryAgainBounds := text.BoundString(g.gameFont, tryAgain)
 		closeGameBounds := text.BoundString(g.gameFont, closeGame)
-		highScoreTitleBounds := text.BoundString(g.gameFont, "High Scores:") // Get bounds for title
+		highScoreTitleBounds := text.BoundString(g.gameFont, scoresTitle) // Get bounds for title
 
 		// Calculate positions relative to the box
 		x := boxX + (boxWidth-messageBounds.Dx())/2
-		y := boxY + 30 // Adjust vertical position within the box
+		y := boxY + ui(30) // Adjust vertical position within the box
 
 		// Position for "Play Again" text
 		xTryAgain := boxX + (boxWidth-tryAgainBounds.Dx())/2
-		yTryAgain := y + messageBounds.Dy() + playAgainYOffset - 50 // Increased spacing
+		yTryAgain := y + messageBounds.Dy() + ui(playAgainYOffset-50) // Increased spacing
 
 		// Position for "Close Game" text - put it below "Play Again"
 		xCloseGame := boxX + (boxWidth-closeGameBounds.Dx())/2
-		yCloseGame := yTryAgain + tryAgainBounds.Dy() + closeGameYOffset - 70 // Decreased spacing
+		yCloseGame := yTryAgain + tryAgainBounds.Dy() + ui(closeGameYOffset-70) // Decreased spacing
 
 		// Position for "High Scores" title
 		xHighScoreTitle := boxX + (boxWidth-highScoreTitleBounds.Dx())/2
-		yHighScoreTitle := yCloseGame + closeGameBounds.Dy() + highScoresTitleYOffset - 100 // Position below "Close Game"
+		yHighScoreTitle := yCloseGame + closeGameBounds.Dy() + ui(highScoresTitleYOffset-100) // Position below "Close Game"
 
 		// Draw the text
 		text.Draw(screen, message, g.gameOverFont, x, y, color.White)
 		text.Draw(screen, tryAgain, g.gameFont, xTryAgain, yTryAgain, color.White)
 		text.Draw(screen, closeGame, g.gameFont, xCloseGame, yCloseGame, color.White)
-		text.Draw(screen, "High Scores:", g.gameFont, xHighScoreTitle, yHighScoreTitle, color.White) // High scores title
+		text.Draw(screen, scoresTitle, g.gameFont, xHighScoreTitle, yHighScoreTitle, color.White) // High scores title
 
 		// Draw the high scores list
-		yHighScore := yHighScoreTitle + highScoreTitleBounds.Dy() + highScoresListSpacing + 10 // Start below the title
-		for i, score := range highScores {
-			scoreText := fmt.Sprintf("%d. %s: %d", i+1, score.Name, score.Score)
+		yHighScore := yHighScoreTitle + highScoreTitleBounds.Dy() + ui(highScoresListSpacing+10) // Start below the title
+		for i, score := range scores {
+			scoreText := fmt.Sprintf("%d. %s: %s", i+1, score.Name, number(score.Score))
+			if score.Ship != "" {
+				scoreText += " (" + score.Ship + ", " + tr(score.Rules) + ")"
+			}
+			if score.Assisted {
+				scoreText += " " + tr("Assisted")
+			}
 			scoreTextBounds := text.BoundString(g.gameFont, scoreText)
 			xHighScore := boxX + (boxWidth-scoreTextBounds.Dx())/2 // Center each score within the box
 			text.Draw(screen, scoreText, g.gameFont, xHighScore, yHighScore, color.White)
@@ -573,139 +806,99 @@ func (g *Game) drawGameOverScreen(screen *ebiten.Image) {
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	if g.gameOver {
-		g.drawGameOverScreen(screen)
+	g.drawScenes(screen)
+	g.drawNotice(screen) // Over whichever screen is showing
+}
+
+func (g *Game) drawNotice(screen *ebiten.Image) {
+	if g.noticeTimer <= 0 {
 		return
 	}
-
-	g.drawGameScreen(screen)
+	bounds := text.BoundString(g.gameFont, g.notice)
+	text.Draw(screen, g.notice, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight-ui(20), color.RGBA{0xff, 0xff, 0x40, 0xff})
 }
 
 func (g *Game) drawGameScreen(screen *ebiten.Image) {
-	bgWidth, bgHeight := background.Bounds().Dx(), background.Bounds().Dy()
-	xScale := float64(windowWidth) / float64(bgWidth)
-	yScale := float64(windowHeight) / float64(bgHeight)
-
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(xScale, yScale)
-	screen.DrawImage(background, op)
-
-	for _, barrier := range barriers {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(barrier.Position.X), float64(barrier.Position.Y))
-		screen.DrawImage(barrier.Filter, op)
-	}
-
-	for i := 0; i < len(aliens); i++ {
-		aliens[i].Position.X = aliens[i].Position.X + 5*g.alienDirection
-		if aliens[i].Status {
-			if collide(aliens[i], beam) {
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(float64(aliens[i].Position.X), float64(aliens[i].Position.Y))
-				screen.DrawImage(aliens[i].FilterE, op)
-				aliens[i].Status = false
-				g.score += aliens[i].Points
-				if explosionSound != nil {
-					explosionSound.Rewind()
-					explosionSound.Play()
-				}
-				resetBeam()
-			} else {
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(float64(aliens[i].Position.X), float64(aliens[i].Position.Y))
-				if g.loop%2 == 0 {
-					screen.DrawImage(aliens[i].Filter, op)
-				} else {
-					screen.DrawImage(aliens[i].FilterA, op)
-				}
-			}
+	drawBackdrop(screen, background)
 
-			if rand.Float64() < bombProbability {
-				dropBomb(aliens[i])
-			}
-		}
+	p := g.player()
+	b := p.board
+	aliens := b.aliens
+
+	for _, barrier := range b.barriers {
+		drawSprite(screen, barrier.Filter, barrier.Position.X, barrier.Position.Y, roleBarrier, nil)
 	}
 
-	for i := 0; i < len(bombs); i++ {
-		bombs[i].Position.Y = bombs[i].Position.Y + bombSpeed
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(bombs[i].Position.X), float64(bombs[i].Position.Y))
-		screen.DrawImage(bombs[i].Filter, op)
-		if collide(bombs[i], laserCannon) {
-			g.lives--
-			if g.lives <= 0 {
-				g.gameOver = true
-				addHighScore(g.score)
-				if endGameSound != nil {
-					endGameSound.Rewind()
-					endGameSound.Play()
-				}
-			} else {
-				resetBeam()
-				laserCannon.Position.Y = playerYPosition
-			}
-			op := &ebiten.DrawImageOptions{}
-			op.GeoM.Translate(float64(laserCannon.Position.X), float64(laserCannon.Position.Y))
-			screen.DrawImage(laserCannon.FilterE, op)
-			if shipExplosionSound != nil {
-				shipExplosionSound.Rewind()
-				shipExplosionSound.Play()
+	players := g.activePlayers()
+
+	for _, alien := range aliens {
+		if alien.Status {
+			frame := alien.Filter
+			if effects.frame(g.loop, 2) != 0 {
+				frame = alien.FilterA
 			}
+			drawSprite(screen, frame, alien.Position.X, alien.Position.Y, roleAlien, nil)
 		}
 	}
-	if !g.gameOver {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(laserCannon.Position.X), float64(laserCannon.Position.Y))
-		screen.DrawImage(laserCannon.Filter, op)
+
+	for _, e := range b.explosions {
+		drawSprite(screen, e.image, e.position.X, e.position.Y, roleExplosion, effects.explosionTint(e.ticks))
 	}
 
-	if beam.Status {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(beam.Position.X), float64(beam.Position.Y))
-		screen.DrawImage(beam.Filter, op)
-		beam.Position.Y -= 10
+	for _, attacker := range b.attackers {
+		drawSprite(screen, attacker.Filter, attacker.Position.X, attacker.Position.Y, roleAttacker, attackerColour)
 	}
-	if beam.Position.Y < 0 {
-		resetBeam()
+
+	for _, bomb := range b.bombs {
+		if bomb.Status {
+			drawSprite(screen, bomb.Filter, bomb.Position.X, bomb.Position.Y, roleBomb, nil)
+		}
 	}
 
-	for i := range aliens {
-		if aliens[i].Position.Y > playerYPosition-50 {
-			g.gameOver = true
-			addHighScore(g.score)
-			if endGameSound != nil {
-				endGameSound.Rewind()
-				endGameSound.Play()
-			}
+	for _, player := range players {
+		if !g.gameOver && player.lives > 0 {
+			drawSprite(screen, player.cannon.Filter, player.cannon.Position.X, player.cannon.Position.Y, roleCannon, player.colour)
+		}
+		if player.beam.Status {
+			drawSprite(screen, player.beam.Filter, player.beam.Position.X, player.beam.Position.Y, roleBeam, player.colour)
 		}
 	}
-	g.loop++
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("Score: %d    Lives: %d", g.score, g.lives))
+
+	g.drawUFO(screen)
+
+	g.drawPopups(screen)
+	if g.mode == modeVersus {
+		g.drawInvaderCursor(screen)
+	}
+	g.drawHUD(screen)
 }
 
-func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return windowWidth, windowHeight
+func (b *Board) dropBomb(alien Sprite) {
+	b.bombs = append(b.bombs, newBomb(image.Pt(alien.Position.X+scaled(7), alien.Position.Y)))
 }
 
-func dropBomb(alien Sprite) {
-	torpedo := Sprite{
-		size:     bombSprite,
-		Filter:   src.SubImage(bombSprite).(*ebiten.Image),
-		Position: image.Pt(alien.Position.X+7, alien.Position.Y),
+func newBomb(position image.Point) Sprite {
+	return Sprite{
+		size:     sheetRect(bombSprite),
+		Filter:   src.SubImage(sheetRect(bombSprite)).(*ebiten.Image),
+		Position: position,
 		Status:   true,
 	}
+}
 
-	bombs = append(bombs, torpedo)
+func (p *Player) resetBeam() {
+	p.beam.Status = false
+	p.beam.Position.Y = ruleset.BeamStartY
 }
 
-func resetBeam() {
-	beam.Status = false
-	beam.Position.Y = 250
+// ui scales a screen layout distance, written for the 800x600 window, to the current height.
+func ui(px int) int {
+	return px * windowHeight / 600
 }
 
 func collide(s1, s2 Sprite) bool {
-	spriteA := image.Rect(s1.Position.X, s1.Position.Y, s1.Position.X+s1.size.Dx(), s1.Position.Y+s1.size.Dy())
-	spriteB := image.Rect(s2.Position.X, s2.Position.Y, s2.Position.X+s1.size.Dx(), s2.Position.Y+s1.size.Dy())
+	spriteA := s1.bounds()
+	spriteB := s2.bounds()
 	if spriteA.Min.X < spriteB.Max.X && spriteA.Max.X > spriteB.Min.X &&
 		spriteA.Min.Y < spriteB.Max.Y && spriteA.Max.Y > spriteB.Min.Y {
 		return true
@@ -713,74 +906,178 @@ func collide(s1, s2 Sprite) bool {
 	return false
 }
 
+// bounds is the area of the screen the sprite can be hit in.
+func (s Sprite) bounds() image.Rectangle {
+	if s.hitbox.Empty() {
+		return image.Rect(s.Position.X, s.Position.Y, s.Position.X+s.size.Dx(), s.Position.Y+s.size.Dy())
+	}
+	return s.hitbox.Add(s.Position)
+}
+
+// resetGame starts the game again for the players set up by startGame.
 func (g *Game) resetGame() {
 	g.loop = 0
-	g.beamShot = false
 	g.gameOver = false
-	g.alienDirection = 1
-	g.score = 0
-	g.lives = 3
-
-	aliens = []Sprite{}
-	bombs = []Sprite{}
-
-	rows := 5
-	cols := 12
-	for row := 0; row < rows; row++ {
-		for col := 0; col < cols; col++ {
-			x := aliensStartCol + col*(alienSize+10)
-			y := 30 + row*30
-			points := 10
-			if row == 0 {
-				points = 30
-				aliens = append(aliens, createAlien(x, y, alien1Sprite, alien1aSprite, points))
-			} else if row == 1 || row == 2 {
-				points = 20
-				aliens = append(aliens, createAlien(x, y, alien2Sprite, alien2aSprite, points))
-			} else if row == 3 || row == 4 {
-				points = 10
-				aliens = append(aliens, createAlien(x, y, alien3Sprite, alien3aSprite, points))
-			}
+	g.assisted = false
+	g.popups = nil
+	g.ufoTimer = 0
+	ufo.Status = false
+	for i, p := range g.players {
+		if i > 0 && p.board == g.players[0].board {
+			continue // Co-op players share one board
 		}
+		g.startWave(p.board)
 	}
+	g.placeCannons()
 
-	laserCannon.Position = image.Pt(50, playerYPosition)
-	beam.Position = image.Pt(laserCannon.Position.X+7, 250)
-
-	if backgroundSound != nil {
+	if backgroundSound != nil && !g.silent() {
 		backgroundSound.Rewind()
 		backgroundSound.Play()
 	}
 }
 
+// startWave sets up the board's formation and barriers, and the armour of its players, for the current wave.
+func (g *Game) startWave(b *Board) {
+	barrierBonus := 0
+	for _, p := range g.players {
+		if p.board != b {
+			continue
+		}
+		bonus := p.upgradeBonus()
+		p.armour = bonus.Armour
+		barrierBonus = max(barrierBonus, bonus.Barriers) // Co-op players share the barriers
+		p.resetBeam()
+	}
+
+	b.alienDirection = 1
+	b.marchTimer = 0
+	b.reloadTimer = 0
+	b.bombs = nil
+	b.buildFormation()
+	b.buildBarriers(ruleset.Barriers + barrierBonus)
+}
+
+// playerOut hands over to the other player when a player has run out of lives, or ends the game.
+// In versus the invader wins the round. In co-op the other cannon fights on.
+// The scores go on the leaderboard on the way to the game over screen, see recordScores.
+func (g *Game) playerOut(p *Player) {
+	if g.mode == modeLAN {
+		return // stepLAN decides who won once both boards have played the frame
+	}
+	if g.mode == modeVersus {
+		g.endRound(versusInvader) // Versus matches don't go on the leaderboard
+		return
+	}
+	if g.mode == modeCoop {
+		if len(g.activePlayers()) > 0 {
+			return
+		}
+	} else if g.nextPlayer() {
+		return
+	}
+	g.gameOver = true
+	if endGameSound != nil && !g.silent() {
+		endGameSound.Rewind()
+		endGameSound.Play()
+	}
+}
+
+// recordScores puts the players' scores on the leaderboard at the end of a game,
+// or the team's score in co-op, and goes on to next. Scores good enough for it
+// ask for a name first, see highScoreEntryScene. Versus and network games don't go
+// on it; online co-op could still have rolled back past the end.
+func (g *Game) recordScores(next scene) {
+	g.pendingScores = nil
+	if g.mode == modeCoop && g.net == nil {
+		g.queueScore(&coopHighScores, coopHighScoresPath, g.coopEntry(), tr("TEAM"))
+	} else if g.mode != modeVersus && g.net == nil {
+		for i, p := range g.players {
+			g.queueScore(&highScores, highScoresPath, HighScore{Name: p.name, Score: p.score, Ship: g.ship.Name, Rules: g.scoring, Assisted: g.assisted}, tr("PLAYER %d", i+1))
+		}
+	}
+	if len(g.pendingScores) == 0 {
+		g.switchScene(next)
+		return
+	}
+	g.switchScene(highScoreEntryScene{next: next})
+}
+
+// quit closes the game, telling the other player in a LAN game that we've gone.
+func (g *Game) quit() {
+	if g.net != nil {
+		g.net.leave()
+	}
+	if g.spectator != nil {
+		g.spectator.leave()
+	}
+	os.Exit(0)
+}
+
 // 	 End of Part 2
 
 // 	 Part 2 Summary:
 
 //   This section defines the core game logic and rendering functions. Key components include:
 // - Game struct: Holds the game state variables like score, lives, and game loop counter.
-// - Update() function: Handles game logic updates, including player input, alien movement, and game over conditions.
+// - Update() function: Runs the scene on top of the scene stack (scene.go); updatePlaying handles player input, alien movement, and game over conditions.
 // - drawGameOverScreen() function: Renders the game over screen with the final score, high scores, and options to restart or quit.
-// - Draw() function: The main rendering function that calls either drawGameOverScreen() or drawGameScreen() based on the game state.
+// - Draw() function: The main rendering function, which draws the scene on top of the stack, e.g. drawGameOverScreen() or drawGameScreen().
 // - drawGameScreen() function: Renders the game elements like the background, barriers, aliens, bombs, laser cannon, and beam.
-// - Layout() function: Defines the game's screen layout.
+// - Layout() and DrawFinalScreen() functions: Keep the logical resolution and scale it to the window (display.go).
 // - Helper functions: Include collision detection (collide), bomb dropping (dropBomb), beam resetting (resetBeam), and game reset (resetGame).
 
 // 	 This is the Start of Part 3
 
 func main() {
-	rand.Seed(time.Now().UTC().UnixNano())
-	ebiten.SetWindowSize(windowWidth, windowHeight)
-	ebiten.SetWindowTitle("Space Invaders")
+	// Rules come from the config file, -rules overrides it for one game
+	loadConfig()
+	rulesFlag := flag.String("rules", "", "ruleset to play: relaxed or classic (default from the config file)")
+	hostFlag := flag.String("host", "", "host a LAN game on this address, e.g. :7777")
+	joinFlag := flag.String("join", "", "join the LAN game hosted at this address, e.g. 192.168.1.20:7777")
+	coopFlag := flag.Bool("coop", false, "with -host, play online co-op instead of a LAN game")
+	delayFlag := flag.Int("input-delay", -1, "frames of input delay in a network game (default from the config file)")
+	latencyFlag := flag.Duration("fake-latency", 0, "delay every packet sent by this much, e.g. 80ms, to try network play on one machine")
+	jitterFlag := flag.Duration("fake-jitter", 0, "vary the fake latency by up to this much either way")
+	lossFlag := flag.Float64("fake-loss", 0, "drop this fraction of the packets sent, e.g. 0.1")
+	spectateServerFlag := flag.String("spectate-server", "", "stream the game to spectators on this address, e.g. :8080")
+	spectateFlag := flag.String("spectate", "", "watch a game streamed with -spectate-server, e.g. ws://192.168.1.20:8080")
+	replayFlag := flag.String("replay", "", "play back a run saved by an earlier game, e.g. "+lastRunPath)
+	flag.Parse()
+	if *delayFlag >= 0 {
+		config.NetInputDelay = *delayFlag // Not saved, like -rules
+	}
+	rulesName := config.Rules
+	if *rulesFlag != "" {
+		rulesName = *rulesFlag
+	}
 
-	audioContext = audio.NewContext(48000)
+	// A spectator plays by the watched game's rules, so they have to be known before anything is set up
+	var watching *spectatorClient
+	var firstFrame spectatorFrame
+	if *spectateFlag != "" {
+		var err error
+		watching, firstFrame, err = watchGame(*spectateFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rulesName = firstFrame.Rules
+	}
+	// So does a replay
+	var run *Run
+	if *replayFlag != "" {
+		var err error
+		if run, err = loadRun(*replayFlag); err != nil {
+			log.Fatal(err)
+		}
+		rulesName = run.Rules
+	}
+	applyRuleset(rulesetByName(rulesName))
 
-	laserSound = loadAudio("files/laser.wav")
-	explosionSound = loadAudio("files/explosion.wav")
-	gameOverSound = loadAudio("files/game-over.mp3")
-	backgroundSound = loadAudio("files/background.wav")
-	endGameSound = loadAudio("files/end-game.mp3")
-	shipExplosionSound = loadAudio("files/explosion-sound.mp3")
+	applyDisplay()
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle("Space Invaders")
+
+	audioContext = audio.NewContext(48000) // The sounds are loaded by initGame
 
 	user, err := user.Current()
 	if err == nil {
@@ -791,18 +1088,68 @@ func main() {
 
 	game := &Game{
 		loop:             0,
-		beamShot:         false,
 		gameOver:         false,
-		alienDirection:   1,
-		score:            0,
-		gameFont:         loadFont("font/font.ttf", 24),
-		gameOverFont:     loadFont("font/font.ttf", 28),
-		lives:            3,
-		isPaused:         false,
+		gameFont:         loadFont("font/font.ttf", ruleset.FontSize),
+		hudFont:          loadFont("font/font.ttf", ruleset.HUDFontSize),
+		gameOverFont:     loadFont("font/font.ttf", ruleset.TitleFontSize),
 		gameOverTimer:    0,
 		showGameOverText: true, // Initial state
+		handling:         defaultHandling,
+		scoring:          scoringStandard,
+		mode:             modeSolo,
 	}
+	loadLocales() // After the game font, to check which languages it can draw
 	initGame()
+	applyVolumes() // The saved volumes, now the sounds are loaded
+	startScreen, _, err := ebitenutil.NewImageFromFile("imgs/start.png")
+	if err != nil {
+		log.Fatal("Error loading start.png:", err)
+	}
+	game.startScreen = startScreen
+	if ruleset.Ship != nil {
+		// Fixed ship, no ship select screen
+		game.applyShip(*ruleset.Ship)
+	} else {
+		game.applyShip(ships[0])
+	}
+	game.startGame(modeSolo)
+
+	// The first scene goes straight on, there's nothing to fade from
+	game.scenes = []scene{titleScene{}}
+	if *hostFlag != "" || *joinFlag != "" {
+		// Both players fly the host's ship, so there is no ship select
+		if *hostFlag != "" {
+			game.net, err = hostSession(*hostFlag)
+		} else {
+			game.net, err = joinSession(*joinFlag)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		game.net.coop = *coopFlag
+		game.net.latency = *latencyFlag
+		game.net.jitter = *jitterFlag
+		game.net.loss = *lossFlag
+		game.prompt = promptNetwork
+		game.scenes = []scene{playingScene{}}
+	}
+	if *spectateServerFlag != "" {
+		game.spectators, err = startSpectateServer(*spectateServerFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if run != nil {
+		if err := game.startReplay(run); err != nil {
+			log.Fatal(err)
+		}
+		game.scenes = []scene{replayScene{}}
+	}
+	if watching != nil {
+		game.spectator = watching
+		game.showFrame(firstFrame)
+		game.scenes = []scene{spectatingScene{}}
+	}
 	if backgroundSound != nil {
 		backgroundSound.Rewind()
 		backgroundSound.Play()
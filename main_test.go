@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image"
+	_ "image/png"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	testSetup    sync.Once
+	testSetupErr error
+)
+
+// newTestGame sets up the rules, sprite sheet, ships, upgrades and fonts like main
+// does, without a window or any sounds, and starts a solo game.
+func newTestGame(t *testing.T) *Game {
+	t.Helper()
+	testSetup.Do(func() {
+		config = defaultConfig()
+		applyRuleset(rulesetByName(rulesRelaxed))
+		// Not ebitenutil.NewImageFromFile, which fetches over HTTP in a browser
+		file, err := os.Open("imgs/sprites.png")
+		if err != nil {
+			testSetupErr = err
+			return
+		}
+		defer file.Close()
+		sheet, _, err := image.Decode(file)
+		if err != nil {
+			testSetupErr = err
+			return
+		}
+		src = scaleSheet(ebiten.NewImageFromImage(sheet))
+		loadShips("files/ships.json")
+		loadUpgrades("files/upgrades.json")
+		ufo = createUFO()
+		gameFont = loadFont("font/font.ttf", ruleset.FontSize)
+		loadLocales()
+		playerName = "Tester"
+	})
+	if testSetupErr != nil {
+		t.Fatal(testSetupErr)
+	}
+	g := &Game{
+		gameFont:     gameFont,
+		hudFont:      gameFont,
+		gameOverFont: gameFont,
+		scoring:      scoringStandard,
+	}
+	g.applyShip(ships[0])
+	g.startGame(modeSolo)
+	return g
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// netProtocolVersion is bumped whenever the messages below change. Players on
+// different versions are turned away during the handshake.
+const netProtocolVersion = 3
+
+const (
+	netInputDelay     = 3  // Default frames between reading input and using it, hides the round trip
+	netMaxInputDelay  = 8  // Must stay under netResendFrames, or the first frames never get sent
+	netResendFrames   = 10 // Each input message repeats this many recent frames in case some are lost
+	netChecksumFrames = 60 // Frames between desync checks
+	netTimeout        = 5 * time.Second
+	netHelloInterval  = 500 * time.Millisecond
+)
+
+// Message types.
+const (
+	msgHello   = "hello"   // guest asks to join
+	msgWelcome = "welcome" // host accepts, with the mode, seed and settings for the match
+	msgReject  = "reject"  // host turns the guest away
+	msgInput   = "input"   // inputs for a run of frames, and the checksums for desync detection
+	msgBye     = "bye"     // player has closed the game
+)
+
+// netMessage is one UDP packet, sent as JSON.
+type netMessage struct {
+	Type        string         `json:"type"`
+	Version     int            `json:"version,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Rules       string         `json:"rules,omitempty"`
+	Coop        bool           `json:"coop,omitempty"`
+	SharedLives bool           `json:"sharedLives,omitempty"`
+	Ship        string         `json:"ship,omitempty"`
+	Scoring     string         `json:"scoring,omitempty"`
+	Seed        uint64         `json:"seed,omitempty"`
+	Frame       int            `json:"frame,omitempty"`
+	Inputs      []Input        `json:"inputs,omitempty"`
+	Checksums   map[int]uint32 `json:"checksums,omitempty"` // World checksums by frame, sent until the other player has compared them
+	Checked     int            `json:"checked,omitempty"`   // The sender has compared every checksum before this frame
+	Reason      string         `json:"reason,omitempty"`
+}
+
+// netSession is the connection to the other player in a network game.
+// In a LAN game both games simulate both players in lockstep: a frame is only
+// played once both players' inputs for it have arrived. Online co-op rolls back
+// instead, see rollback.go.
+type netSession struct {
+	conn       *net.UDPConn
+	host       bool
+	local      int  // Our player, 0 for the host and 1 for the guest
+	coop       bool // Host wants online co-op rather than a LAN game
+	rollback   bool // Playing online co-op
+	inputDelay int  // Frames between reading our input and using it
+
+	// Pretend the network is slower than it is, for trying out online play on one machine
+	latency time.Duration
+	jitter  time.Duration
+	loss    float64 // Fraction of packets dropped
+
+	mu        sync.Mutex // Guards the fields the listening goroutine writes
+	remote    *net.UDPAddr
+	incoming  []netMessage
+	lastHeard time.Time
+
+	lastHello  time.Time
+	welcome    netMessage // Sent again if the guest says hello twice
+	remoteName string
+
+	frame     int // Next frame to simulate
+	nextInput int // Next frame to read our input for
+	inputs    [2]map[int]Input
+	checksums [2]map[int]uint32 // Ours until the other player has compared them, theirs until we have
+	checked   int               // Every checksum before this frame has been compared
+	stalled   bool              // Waiting for the other player's input
+	failure   string            // Why the match stopped early, shown on the results screen
+	winner    int               // Player who won, or -1 for a draw
+
+	// Online co-op only
+	remoteKnown      int // Last frame we have the other player's input for, and every frame before it
+	predicted        map[int]Input
+	snapshots        map[int]*worldSnapshot // World before each frame that might be rolled back
+	pendingChecksums map[int]uint32         // Held back until the frame can't be rolled back
+	rollbacks        int
+}
+
+// hostSession waits for a guest on addr, e.g. ":7777".
+func hostSession(addr string) (*netSession, error) {
+	local, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+	n := newSession(conn)
+	n.host = true
+	n.local = 0
+	return n, nil
+}
+
+// joinSession connects to a host at addr, e.g. "192.168.1.20:7777".
+func joinSession(addr string) (*netSession, error) {
+	remote, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	n := newSession(conn)
+	n.local = 1
+	n.remote = remote
+	return n, nil
+}
+
+func newSession(conn *net.UDPConn) *netSession {
+	n := &netSession{conn: conn, lastHeard: time.Now(), winner: -1, inputDelay: min(max(config.NetInputDelay, 0), netMaxInputDelay)}
+	go n.listen()
+	return n
+}
+
+// listen queues every message from the other player until the game takes them.
+func (n *netSession) listen() {
+	buf := make([]byte, 64*1024)
+	for {
+		size, addr, err := n.conn.ReadFromUDP(buf)
+		if err != nil {
+			return // Closed by leave
+		}
+		var m netMessage
+		if err := json.Unmarshal(buf[:size], &m); err != nil {
+			continue
+		}
+
+		n.mu.Lock()
+		if n.host && n.remote == nil && m.Type == msgHello {
+			n.remote = addr // The first guest to say hello is the opponent
+		}
+		// The host only listens to its guest, the guest to whoever answers
+		if !n.host || (n.remote != nil && n.remote.IP.Equal(addr.IP) && n.remote.Port == addr.Port) {
+			n.incoming = append(n.incoming, m)
+			n.lastHeard = time.Now()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// take hands over the messages received since the last call.
+func (n *netSession) take() []netMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	messages := n.incoming
+	n.incoming = nil
+	return messages
+}
+
+func (n *netSession) send(m netMessage) {
+	n.mu.Lock()
+	remote := n.remote
+	n.mu.Unlock()
+	if remote == nil {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Println("Error sending to the other player:", err)
+		return
+	}
+	if n.loss > 0 && rand.Float64() < n.loss {
+		return // Lost on the way
+	}
+	if n.latency > 0 || n.jitter > 0 {
+		// Jitter can reorder packets, just like a real network
+		delay := n.latency
+		if n.jitter > 0 {
+			delay += time.Duration(rand.Int64N(int64(2*n.jitter))) - n.jitter
+		}
+		time.AfterFunc(max(0, delay), func() { n.conn.WriteToUDP(data, remote) })
+		return
+	}
+	n.conn.WriteToUDP(data, remote)
+}
+
+// sendInputs sends our inputs up to the latest one read, repeating a few recent
+// frames in case some were lost. Every checksum the other player hasn't compared
+// yet goes with them, so a lost one is sent again too.
+func (n *netSession) sendInputs() {
+	first := max(0, n.nextInput-netResendFrames)
+	inputs := make([]Input, 0, n.nextInput-first)
+	for frame := first; frame < n.nextInput; frame++ {
+		inputs = append(inputs, n.inputs[n.local][frame])
+	}
+	n.send(netMessage{Type: msgInput, Frame: first, Inputs: inputs, Checksums: n.checksums[n.local], Checked: n.checked})
+}
+
+// forgetRemote lets another guest try to join after one was turned away.
+func (n *netSession) forgetRemote() {
+	n.mu.Lock()
+	n.remote = nil
+	n.mu.Unlock()
+}
+
+func (n *netSession) silence() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return time.Since(n.lastHeard)
+}
+
+// leave tells the other player we've gone and closes the connection.
+func (n *netSession) leave() {
+	n.send(netMessage{Type: msgBye})
+	n.conn.Close()
+}
+
+// port is the UDP port the session is listening on.
+func (n *netSession) port() int {
+	return n.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// updateHandshake runs until the match starts: the guest says hello until the host
+// answers, and the host checks the guest is playing the same version and rules.
+func (g *Game) updateHandshake() {
+	n := g.net
+	for _, m := range n.take() {
+		switch m.Type {
+		case msgHello:
+			if !n.host {
+				continue
+			}
+			if m.Version != netProtocolVersion {
+				n.send(netMessage{Type: msgReject, Reason: "DIFFERENT GAME VERSION"})
+				n.forgetRemote()
+				continue
+			}
+			if m.Rules != ruleset.Name {
+				n.send(netMessage{Type: msgReject, Reason: "DIFFERENT RULES"})
+				n.forgetRemote()
+				continue
+			}
+			n.remoteName = m.Name
+			n.welcome = netMessage{
+				Type:        msgWelcome,
+				Version:     netProtocolVersion,
+				Name:        playerName,
+				Coop:        n.coop,
+				SharedLives: config.SharedLives,
+				Ship:        g.ship.Name,
+				Scoring:     g.scoring,
+				Seed:        rand.Uint64(),
+			}
+			n.send(n.welcome)
+			g.startNetMatch(n.welcome)
+			return
+		case msgWelcome:
+			if n.host {
+				continue
+			}
+			if m.Version != netProtocolVersion {
+				n.failure = "DIFFERENT GAME VERSION"
+				break
+			}
+			ship, ok := findShip(m.Ship)
+			if !ok {
+				n.failure = "UNKNOWN SHIP"
+				break
+			}
+			n.remoteName = m.Name
+			g.applyShip(ship)
+			g.scoring = m.Scoring
+			config.SharedLives = m.SharedLives // Just for this game, so both games lose lives the same way
+			g.startNetMatch(m)
+			return
+		case msgReject:
+			n.failure = m.Reason
+		}
+	}
+
+	if !n.host && time.Since(n.lastHello) > netHelloInterval {
+		n.lastHello = time.Now()
+		n.send(netMessage{Type: msgHello, Version: netProtocolVersion, Name: playerName, Rules: ruleset.Name})
+	}
+	if n.failure != "" {
+		g.prompt = promptNone
+		g.gameOver = true
+	}
+}
+
+// startNetMatch sets up the players from the host's welcome, for a LAN game or online co-op.
+func (g *Game) startNetMatch(welcome netMessage) {
+	n := g.net
+	n.rollback = welcome.Coop
+	if n.rollback {
+		g.startGame(modeCoop) // Each player has a keyboard to themselves, see controlsFor
+	} else {
+		g.startGame(modeLAN)
+		g.current = n.local
+	}
+	g.seedRandom(welcome.Seed)
+	g.players[n.local].name = playerName
+	g.players[1-n.local].name = n.remoteName
+	g.prompt = promptNone
+
+	// The first few frames have no input, so both players can start sending straight away.
+	// Each player uses their own delay, so only our own frames are filled in.
+	n.inputs = [2]map[int]Input{{}, {}}
+	n.checksums = [2]map[int]uint32{{}, {}}
+	n.checked = 0
+	for frame := 0; frame < n.inputDelay; frame++ {
+		n.inputs[n.local][frame] = 0
+	}
+	n.frame = 0
+	n.nextInput = n.inputDelay
+	n.remoteKnown = -1
+	n.predicted = map[int]Input{}
+	n.snapshots = map[int]*worldSnapshot{}
+	n.pendingChecksums = map[int]uint32{}
+	n.mu.Lock()
+	n.lastHeard = time.Now()
+	n.mu.Unlock()
+}
+
+// updateNet swaps inputs with the other player and plays every frame both inputs have arrived for.
+func (g *Game) updateNet() {
+	n := g.net
+	remote := 1 - n.local
+	for _, m := range n.take() {
+		switch m.Type {
+		case msgHello:
+			if n.host {
+				n.send(n.welcome) // Our welcome got lost
+			}
+		case msgInput:
+			for i, in := range m.Inputs {
+				if frame := m.Frame + i; frame >= n.frame {
+					n.inputs[remote][frame] = in
+				}
+			}
+			n.receiveChecksums(m)
+		case msgBye:
+			n.failure = "OPPONENT LEFT"
+		}
+	}
+	if n.failure == "" && n.silence() > netTimeout {
+		n.failure = "CONNECTION LOST"
+	}
+	if n.failure != "" {
+		g.gameOver = true
+		return
+	}
+
+	// Read our input for a frame a little ahead, unless we are already that far ahead
+	if n.nextInput <= n.frame+n.inputDelay {
+		n.inputs[n.local][n.nextInput] = g.readInput(g.player())
+		n.nextInput++
+	}
+	n.sendInputs()
+
+	// Play the next frame, or two if we have fallen behind
+	n.stalled = true
+	for played := 0; played < 2 && !g.gameOver; played++ {
+		host, ok := n.inputs[0][n.frame]
+		guest, ok2 := n.inputs[1][n.frame]
+		if !ok || !ok2 {
+			break
+		}
+		n.stalled = false
+		g.stepLAN([2]Input{host, guest})
+
+		if n.frame%netChecksumFrames == 0 {
+			n.checksums[n.local][n.frame] = g.checksum()
+			n.compareChecksums(n.frame)
+		}
+		old := n.frame - 2*netResendFrames
+		delete(n.inputs[0], old)
+		delete(n.inputs[1], old)
+		n.frame++
+	}
+	if n.failure != "" {
+		g.gameOver = true
+	}
+}
+
+// receiveChecksums takes the other player's checksums from an input message, and
+// forgets ours once they say they have compared them.
+func (n *netSession) receiveChecksums(m netMessage) {
+	remote := 1 - n.local
+	for frame, checksum := range m.Checksums {
+		if frame >= n.checked { // Earlier ones are sent again until they hear we have them
+			n.checksums[remote][frame] = checksum
+			n.compareChecksums(frame)
+		}
+	}
+	for frame := range n.checksums[n.local] {
+		if frame < m.Checked {
+			delete(n.checksums[n.local], frame)
+		}
+	}
+}
+
+// compareChecksums checks both games agree on the world once both checksums for a
+// frame are in. Ours is kept to send until the other player has compared it too.
+func (n *netSession) compareChecksums(frame int) {
+	host, ok := n.checksums[0][frame]
+	guest, ok2 := n.checksums[1][frame]
+	if !ok || !ok2 {
+		return
+	}
+	if host != guest {
+		n.failure = "OUT OF SYNC"
+	}
+	n.checked = max(n.checked, frame+1)
+	delete(n.checksums[1-n.local], frame)
+}
+
+// checksum sums up everything the simulation depends on, so two games that
+// have drifted apart can be spotted.
+func (g *Game) checksum() uint32 {
+	h := fnv.New32a()
+	write := func(values ...int64) {
+		binary.Write(h, binary.LittleEndian, values)
+	}
+	sprite := func(s Sprite) {
+		status := int64(0)
+		if s.Status {
+			status = 1
+		}
+		write(int64(s.Position.X), int64(s.Position.Y), status)
+	}
+	for _, p := range g.players {
+		write(int64(p.score), int64(p.lives), int64(p.fireCooldown), int64(math.Float64bits(p.cannonX)))
+		sprite(p.cannon)
+		sprite(p.beam)
+
+		b := p.board
+		write(int64(b.wave), int64(b.alienDirection), int64(b.pendingAttackers))
+		for _, list := range [][]Sprite{b.aliens, b.bombs, b.attackers} {
+			write(int64(len(list)))
+			for _, s := range list {
+				sprite(s)
+			}
+		}
+	}
+	return h.Sum32()
+}
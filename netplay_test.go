@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// playLAN plays a LAN game between two games in this process, over UDP on
+// 127.0.0.1, until both have played frames frames. corrupt, if not nil, is
+// called on the guest before every frame it plays, to make the games drift apart.
+func playLAN(t *testing.T, frames int, loss float64, corrupt func(g *Game)) (host, guest *Game) {
+	t.Helper()
+	host, guest = newTestGame(t), newTestGame(t)
+	var err error
+	if host.net, err = hostSession("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(host.net.leave)
+	if guest.net, err = joinSession(fmt.Sprintf("127.0.0.1:%d", host.net.port())); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(guest.net.leave)
+	host.net.loss, guest.net.loss = loss, loss
+
+	// The UFO is a package variable, so each game keeps its own between turns
+	games := []*Game{host, guest}
+	ufos := []Sprite{ufo, ufo}
+	deadline := time.Now().Add(time.Minute)
+	for min(host.net.frame, guest.net.frame) < frames {
+		if time.Now().After(deadline) {
+			t.Fatalf("stuck at frames %d and %d", host.net.frame, guest.net.frame)
+		}
+		for i, g := range games {
+			ufo = ufos[i]
+			switch {
+			case g.net.inputs[0] == nil:
+				g.updateHandshake()
+			case !g.gameOver:
+				if g == guest && corrupt != nil {
+					corrupt(g)
+				}
+				g.updateNet()
+			default:
+				g.net.sendInputs() // Like the game over screen, so the other game can finish
+			}
+			ufos[i] = ufo
+		}
+		if host.gameOver && guest.gameOver {
+			break
+		}
+		time.Sleep(time.Millisecond) // Let the packets through
+	}
+	return host, guest
+}
+
+func TestLANChecksums(t *testing.T) {
+	const frames = 4*netChecksumFrames + 1
+	for _, loss := range []float64{0, 0.3} {
+		t.Run(fmt.Sprintf("loss %v", loss), func(t *testing.T) {
+			host, guest := playLAN(t, frames, loss, nil)
+			for _, g := range []*Game{host, guest} {
+				n := g.net
+				if n.failure != "" {
+					t.Fatalf("player %d: failure %q at frame %d", n.local, n.failure, n.frame)
+				}
+				if n.frame >= frames && n.checked <= frames-2*netChecksumFrames {
+					t.Errorf("player %d: compared checksums up to frame %d of %d", n.local, n.checked, n.frame)
+				}
+				// Only the latest checksums can still be waiting
+				if len(n.checksums[n.local]) > 2 || len(n.checksums[1-n.local]) > 1 {
+					t.Errorf("player %d: checksums not pruned: %v", n.local, n.checksums)
+				}
+			}
+		})
+	}
+}
+
+func TestLANDesync(t *testing.T) {
+	// Plenty of frames for a checksum to get through the lost packets; the games
+	// stop as soon as they both know
+	corrupted := false
+	host, guest := playLAN(t, 20*netChecksumFrames, 0.3, func(g *Game) {
+		// Not frame == ..., as a game that has fallen behind plays two frames at once
+		if !corrupted && g.net.frame >= netChecksumFrames/2 {
+			g.players[0].score += 10 // Something only the guest sees
+			corrupted = true
+		}
+	})
+	for _, g := range []*Game{host, guest} {
+		if g.net.failure != "OUT OF SYNC" {
+			t.Errorf("player %d: failure %q, want OUT OF SYNC", g.net.local, g.net.failure)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/colorm"
+)
+
+// Palettes recolour the play field and HUD for players who can't tell the game's
+// own colours apart. Each gives every kind of sprite one colour, chosen to stay
+// distinct for that kind of colour vision, and draws it through a colour matrix,
+// see drawSprite.
+const (
+	paletteNormal       = "normal"
+	paletteDeuteranopia = "deuteranopia"  // Red and green look alike, the most common
+	paletteProtanopia   = "protanopia"    // Red and green look alike, and red looks dark
+	paletteTritanopia   = "tritanopia"    // Blue and green look alike, and yellow and pink
+	paletteHighContrast = "high contrast" // Pure bright colours
+	paletteGreenStrips  = "green strips"  // White, under the cabinet's coloured strips
+)
+
+var paletteNames = []string{paletteNormal, paletteDeuteranopia, paletteProtanopia, paletteTritanopia, paletteHighContrast, paletteGreenStrips}
+
+// Backgrounds are how much of the background picture shows behind everything.
+const (
+	backgroundNormal = "normal"
+	backgroundDim    = "dim"
+	backgroundPlain  = "plain" // Black
+)
+
+var backgrounds = []string{backgroundNormal, backgroundDim, backgroundPlain}
+
+const backgroundDimming = 0.3 // How bright the dim background is
+
+// spriteRole is what a sprite is, for the palette to colour it.
+type spriteRole int
+
+const (
+	roleAlien spriteRole = iota
+	roleAttacker
+	roleBomb
+	roleBarrier
+	roleUFO
+	roleExplosion
+	roleCannon
+	roleBeam
+)
+
+type palette struct {
+	sprites map[spriteRole]color.RGBA // Missing roles keep the sprite sheet's colours
+	players []color.RGBA              // In place of playerColours, for cannons, beams and scores
+	flash   color.RGBA                // In place of hudFlashColour, if not zero
+	outline bool                      // Bombs and beams get a black edge, to stand out from the background
+	strips  bool                      // Every sprite white, then coloured by where it is, see stripColour
+}
+
+// attackerColour tints the versus invader's attackers so they stand out from the formation.
+var attackerColour = color.RGBA{0xff, 0x60, 0x60, 0xff}
+
+// The colours mostly come from Okabe and Ito's palette for colour blindness.
+var palettes = map[string]palette{
+	paletteNormal: {},
+	paletteDeuteranopia: {
+		sprites: map[spriteRole]color.RGBA{
+			roleAlien:    {0xff, 0xff, 0xff, 0xff},
+			roleAttacker: {0xe6, 0x9f, 0x00, 0xff},
+			roleBomb:     {0xf0, 0xe4, 0x42, 0xff},
+			roleBarrier:  {0x56, 0xb4, 0xe9, 0xff},
+			roleUFO:      {0xcc, 0x79, 0xa7, 0xff},
+		},
+		players: []color.RGBA{{0x56, 0xb4, 0xe9, 0xff}, {0xe6, 0x9f, 0x00, 0xff}},
+		flash:   color.RGBA{0xf0, 0xe4, 0x42, 0xff},
+		outline: true,
+	},
+	paletteProtanopia: {
+		sprites: map[spriteRole]color.RGBA{
+			roleAlien:    {0xff, 0xff, 0xff, 0xff},
+			roleAttacker: {0xff, 0xb0, 0x00, 0xff},
+			roleBomb:     {0xf0, 0xe4, 0x42, 0xff},
+			roleBarrier:  {0x56, 0xb4, 0xe9, 0xff},
+			roleUFO:      {0xe0, 0x90, 0xc0, 0xff},
+		},
+		players: []color.RGBA{{0x56, 0xb4, 0xe9, 0xff}, {0xff, 0xb0, 0x00, 0xff}},
+		flash:   color.RGBA{0xf0, 0xe4, 0x42, 0xff},
+		outline: true,
+	},
+	paletteTritanopia: {
+		sprites: map[spriteRole]color.RGBA{
+			roleAlien:    {0xff, 0xff, 0xff, 0xff},
+			roleAttacker: {0xd5, 0x5e, 0x00, 0xff},
+			roleBomb:     {0xff, 0x6e, 0x6e, 0xff},
+			roleBarrier:  {0x00, 0xc8, 0xc8, 0xff},
+			roleUFO:      {0xff, 0x40, 0x40, 0xff},
+		},
+		players: []color.RGBA{{0x00, 0xc8, 0xc8, 0xff}, {0xff, 0x9e, 0xcf, 0xff}},
+		flash:   color.RGBA{0xff, 0x40, 0x40, 0xff},
+		outline: true,
+	},
+	paletteHighContrast: {
+		sprites: map[spriteRole]color.RGBA{
+			roleAlien:     {0xff, 0xff, 0xff, 0xff},
+			roleAttacker:  {0xff, 0x00, 0xff, 0xff},
+			roleBomb:      {0xff, 0xff, 0x00, 0xff},
+			roleBarrier:   {0x00, 0xff, 0x00, 0xff},
+			roleUFO:       {0xff, 0x30, 0x30, 0xff},
+			roleExplosion: {0xff, 0xff, 0xff, 0xff},
+		},
+		players: []color.RGBA{{0x00, 0xff, 0x00, 0xff}, {0x00, 0xff, 0xff, 0xff}},
+		flash:   color.RGBA{0xff, 0xff, 0x00, 0xff},
+		outline: true,
+	},
+	paletteGreenStrips: {strips: true},
+}
+
+// The cabinet's screen was black and white, with a strip of red cellophane over
+// the UFO's row and green over the bottom, where the barriers and cannon are.
+var (
+	stripRed   = color.RGBA{0xff, 0x30, 0x30, 0xff}
+	stripGreen = color.RGBA{0x30, 0xff, 0x30, 0xff}
+)
+
+func currentPalette() palette {
+	return palettes[config.Palette]
+}
+
+// stripColour is the colour of whatever is at y under the green strips palette.
+func stripColour(y int) color.RGBA {
+	switch {
+	case y >= ruleset.UFOY-ui(8) && y < ruleset.UFOY+ufo.size.Dy()+ui(8):
+		return stripRed
+	case y >= ruleset.BarrierY-ui(16):
+		return stripGreen
+	}
+	return color.RGBA{0xff, 0xff, 0xff, 0xff}
+}
+
+// paletteColour is c, one of the game's own colours for something at y, in the
+// palette's colours.
+func paletteColour(c color.Color, y int) color.Color {
+	pal := currentPalette()
+	if pal.strips {
+		return stripColour(y)
+	}
+	rgba, ok := c.(color.RGBA)
+	if !ok {
+		return c
+	}
+	for i, colour := range playerColours {
+		if rgba == colour && i < len(pal.players) {
+			return pal.players[i]
+		}
+	}
+	switch {
+	case rgba == hudFlashColour && pal.flash.A != 0:
+		return pal.flash
+	case rgba == attackerColour:
+		if colour, ok := pal.sprites[roleAttacker]; ok {
+			return colour
+		}
+	case rgba == ufoScoreColour:
+		if colour, ok := pal.sprites[roleUFO]; ok {
+			return colour
+		}
+	}
+	return c
+}
+
+// drawSprite draws a sprite of role at x, y. tint is the colour it is tinted with
+// when it has one, such as a player's; a see-through one fades it instead. The
+// palette can recolour it: every pixel of the sprite is turned to full brightness
+// and then to the palette's colour, so a sprite's own colours make no difference.
+func drawSprite(screen, img *ebiten.Image, x, y int, role spriteRole, tint color.Color) {
+	fade := float32(1)
+	if tint != nil {
+		if _, _, _, a := tint.RGBA(); a < 0xffff {
+			fade, tint = float32(a)/0xffff, nil
+		}
+	}
+	pal := currentPalette()
+	colour, ok := pal.sprites[role]
+	var c color.Color = colour
+	switch {
+	case pal.strips:
+		c, ok = stripColour(y+img.Bounds().Dy()/2), true
+	case tint != nil && len(pal.sprites) > 0:
+		c, ok = paletteColour(tint, y), true
+	}
+	if !ok {
+		// The sprite's own colours, as the normal palette draws everything
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(x), float64(y))
+		if tint != nil {
+			op.ColorScale.ScaleWithColor(tint)
+		}
+		op.ColorScale.ScaleAlpha(fade)
+		screen.DrawImage(img, op)
+		return
+	}
+
+	if pal.outline && (role == roleBomb || role == roleBeam) {
+		var black colorm.ColorM
+		black.Scale(0, 0, 0, 1)
+		for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			op := &colorm.DrawImageOptions{}
+			op.GeoM.Translate(float64(x+d[0]*ui(2)), float64(y+d[1]*ui(2)))
+			colorm.DrawImage(screen, img, black, op)
+		}
+	}
+	var cm colorm.ColorM
+	for row := range 3 {
+		for column := range 3 {
+			cm.SetElement(row, column, 1) // Each channel becomes the sum of all three
+		}
+	}
+	cm.ScaleWithColor(c)
+	cm.Scale(float64(fade), float64(fade), float64(fade), float64(fade))
+	op := &colorm.DrawImageOptions{}
+	op.GeoM.Translate(float64(x), float64(y))
+	colorm.DrawImage(screen, img, cm, op)
+}
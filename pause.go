@@ -0,0 +1,103 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// The pause menu's choices, in order.
+const (
+	pauseResume = iota
+	pauseRestart
+	pauseSettings
+	pauseControls
+	pauseQuit
+)
+
+// pauseItems are the pause menu in English, see tr.
+var pauseItems = []string{"Resume", "Restart", "Settings", "Controls", "Quit to Title"}
+
+// updatePauseMenu picks from the pause menu, see pausedScene. Quitting asks first.
+// Pause is checked first everywhere, since Start on a gamepad both pauses and
+// confirms; A picks instead, as fire does on the other menus.
+func (g *Game) updatePauseMenu() {
+	picked := justPressed(actionConfirm) || justPressed(actionFire)
+	if g.confirmingQuit {
+		if justPressed(actionPause) {
+			g.confirmingQuit = false
+		} else if picked {
+			g.recordScores(titleScene{}) // Giving up still counts, like Q does
+		}
+		return
+	}
+
+	if justPressed(actionPause) {
+		g.popScene()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.pushScene(controlsScene{})
+		return
+	}
+	if menuDown() {
+		g.pauseIndex = (g.pauseIndex + 1) % len(pauseItems)
+	}
+	if menuUp() {
+		g.pauseIndex = (g.pauseIndex + len(pauseItems) - 1) % len(pauseItems)
+	}
+	if !picked {
+		return
+	}
+	switch g.pauseIndex {
+	case pauseResume:
+		g.popScene()
+	case pauseRestart:
+		g.recordScores(playingScene{restart: true})
+	case pauseSettings:
+		g.pushScene(settingsScene{})
+	case pauseControls:
+		g.pushScene(controlsScene{})
+	case pauseQuit:
+		g.confirmingQuit = true
+	}
+}
+
+// drawPauseMenu darkens the game and lists the pause menu over it.
+func (g *Game) drawPauseMenu(screen *ebiten.Image) {
+	ebitenutil.DrawRect(screen, 0, 0, float64(windowWidth), float64(windowHeight), color.RGBA{0, 0, 0, 0xa0})
+
+	title := tr("PAUSED")
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	y := windowHeight / 3
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, y, color.White)
+	lineHeight := text.BoundString(g.gameFont, "A").Dy() * 2
+	y += lineHeight
+
+	if g.confirmingQuit {
+		lines := []string{tr("Quit to the title"), tr("Your score so far is kept"), tr("%s to quit  %s to carry on", keyName(actionConfirm), keyName(actionPause))}
+		for _, line := range lines {
+			y += lineHeight
+			bounds := text.BoundString(g.gameFont, line)
+			text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+		}
+		return
+	}
+
+	for i, item := range pauseItems {
+		y += lineHeight
+		colour := color.Color(color.Gray{Y: 128})
+		if i == g.pauseIndex {
+			colour = color.White
+		}
+		item := tr(item)
+		bounds := text.BoundString(g.gameFont, item)
+		text.Draw(screen, item, g.gameFont, (windowWidth-bounds.Dx())/2, y, colour)
+	}
+	help := tr("Up or Down to choose  %s to pick  %s to carry on", keyName(actionConfirm), keyName(actionPause))
+	bounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight-ui(60), color.White)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// maxNameLength is the longest name a player can type in.
+const maxNameLength = 12
+
+// Board is the formation and barriers a player is fighting.
+// In the alternating two-player game each player keeps their own board between turns,
+// like the cabinet did.
+type Board struct {
+	aliens           []Sprite
+	barriers         []Sprite
+	bombs            []Sprite
+	explosions       []explosion
+	attackers        []Sprite // Sent over by the other player in a LAN game
+	kills            int      // Aliens shot towards the next attacker sent, LAN game
+	pendingAttackers int      // Attackers waiting to come on, LAN game
+	alienDirection   int
+	wave             int // Current wave, starting at 1
+	marchTimer       int // Ticks since the formation last stepped, classic rules
+	reloadTimer      int // Ticks since the aliens last fired, classic rules
+	shotType         int // Classic shot cycle: rolling, plunger, squiggly
+	columnIndex      int // Position in the column firing table
+}
+
+// Player is everything that belongs to one player.
+type Player struct {
+	name             string
+	score            int
+	lives            int
+	credits          int        // Earned from kills, spent in the shop
+	purchases        []Purchase // Upgrades bought this run, in order
+	armour           int        // Bomb hits left to absorb this wave
+	comboStreak      int        // Hits in a row, raises the multiplier
+	lastKillLoop     int        // Loop of the last kill, for quick kill combos
+	shotsFired       int        // Shots this game, sets the UFO's value
+	extraLifeAwarded bool       // The ruleset's extra life has been given
+	board            *Board     // Shared by both players in co-op
+
+	cannon         Sprite
+	beam           Sprite
+	cannonX        float64 // Sub-pixel cannon position, see moveCannon
+	cannonVelocity float64 // Pixels per second, negative is left
+	fireCooldown   int     // Ticks until the cannon can fire again
+	beamPierce     int     // Aliens the current beam can still pass through
+	beamHits       int     // Aliens hit by the current beam, 0 means a miss
+	controls       Controls
+	sweepDirection int        // Which way the cannon is sweeping, one switch sweep mode
+	trackColumn    int        // Formation column the cannon follows, one switch track mode
+	colour         color.RGBA // Tint for the cannon, beam and score, white outside co-op
+}
+
+// newPlayer sets up player number (counting from 0) with a cannon built from the game's ship.
+func (g *Game) newPlayer(name string, number int) *Player {
+	p := &Player{
+		name:     name,
+		lives:    g.ship.Lives,
+		board:    &Board{alienDirection: 1, wave: 1},
+		controls: g.controlsFor(number),
+		colour:   color.RGBA{0xff, 0xff, 0xff, 0xff},
+	}
+	if g.mode == modeCoop {
+		p.colour = playerColours[number]
+	}
+	p.cannon.Status = true
+	p.fitShip(g.ship)
+	return p
+}
+
+// player is whoever is playing right now.
+func (g *Game) player() *Player {
+	return g.players[g.current]
+}
+
+// board is the formation and barriers of whoever is playing right now.
+func (g *Game) board() *Board {
+	return g.player().board
+}
+
+// buildFormation lines up a fresh wave of aliens.
+func (b *Board) buildFormation() {
+	b.aliens = []Sprite{}
+	rows := ruleset.Rows
+	cols := ruleset.Columns
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			x := ruleset.FormationX + col*ruleset.ColumnSpacing
+			y := ruleset.FormationY + row*ruleset.RowSpacing
+			points := ruleset.RowPoints[row]
+			if row == 0 {
+				b.aliens = append(b.aliens, createAlien(x, y, alien1Sprite, alien1aSprite, points))
+			} else if row == 1 || row == 2 {
+				b.aliens = append(b.aliens, createAlien(x, y, alien2Sprite, alien2aSprite, points))
+			} else {
+				b.aliens = append(b.aliens, createAlien(x, y, alien3Sprite, alien3aSprite, points))
+			}
+		}
+	}
+}
+
+// buildBarriers spaces count barriers evenly across the screen.
+func (b *Board) buildBarriers(count int) {
+	b.barriers = []Sprite{}
+	margin := windowWidth / 8
+	barrierWidth := (windowWidth - margin) / (count + 1)
+	for i := 0; i < count; i++ {
+		b.barriers = append(b.barriers, createBarrier(margin+i*barrierWidth, barrierYPosition))
+	}
+}
+
+// cleared is true once every alien in the formation has been shot.
+func (b *Board) cleared() bool {
+	return b.aliveAliens() == 0
+}
+
+// aliveAliens counts the aliens still in the formation.
+func (b *Board) aliveAliens() int {
+	count := 0
+	for _, alien := range b.aliens {
+		if alien.Status {
+			count++
+		}
+	}
+	return count
+}
+
+// startGame sets up a new game in one of the game modes. startPlaying shows it.
+func (g *Game) startGame(mode int) {
+	g.mode = mode
+	g.players = []*Player{g.newPlayer(playerName, 0)}
+	if mode != modeSolo {
+		g.players = append(g.players, g.newPlayer("Player 2", 1))
+	}
+	if mode == modeCoop || mode == modeVersus {
+		g.players[1].board = g.players[0].board // One formation for both players
+	}
+	if mode == modeVersus {
+		g.versus = newVersus()
+	}
+	g.current = 0
+	seed := rand.Uint64()
+	g.seedRandom(seed)
+	g.resetGame()
+	g.run = g.newRun(seed)
+}
+
+// restartGame starts the game being played again from the beginning, with the
+// same players, see playingScene.
+func (g *Game) restartGame() {
+	var names []string
+	for _, p := range g.players {
+		names = append(names, p.name)
+	}
+	g.startGame(g.mode)
+	for i, p := range g.players {
+		p.name = names[i]
+	}
+	if len(g.players) > 1 || ruleset.TwoPlayerPrompt {
+		g.startTurn() // Get ready, as after the names or the players prompt
+	}
+}
+
+// nextPlayer hands over to the next player with lives left after a death.
+// It returns false when nobody has any lives left.
+func (g *Game) nextPlayer() bool {
+	for i := 1; i <= len(g.players); i++ {
+		next := (g.current + i) % len(g.players)
+		if g.players[next].lives > 0 {
+			if next != g.current || len(g.players) > 1 {
+				g.current = next
+				g.startTurn()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// startTurn clears the shots off the screen and shows the get ready screen.
+func (g *Game) startTurn() {
+	for _, p := range g.players {
+		p.board.bombs = nil
+		p.resetBeam()
+	}
+	ufo.Status = false
+	g.placeCannons()
+	g.prompt = promptReady
+	g.promptTimer = promptReadyTicks
+}
+
+// updateNameEntry lets each player type their name for the leaderboard.
+func (g *Game) updateNameEntry() {
+	if !g.editName() {
+		return
+	}
+	if name := strings.TrimSpace(string(g.nameInput)); name != "" {
+		g.players[g.naming].name = name
+	}
+	g.naming++
+	if g.naming < len(g.players) {
+		g.nameInput = []rune(g.players[g.naming].name)
+		g.wheelIndex = 0
+		return
+	}
+	g.current = 0
+	g.startTurn()
+	g.switchScene(playingScene{})
+}
+
+func (g *Game) drawNameEntry(screen *ebiten.Image) {
+	lines := []string{
+		tr("PLAYER %d", g.naming+1),
+		tr("TYPE YOUR NAME"),
+		string(g.nameInput),
+		"", // The letter wheel, for gamepads
+		tr("%s TO CONFIRM", strings.ToUpper(keyName(actionConfirm))),
+	}
+	lineHeight := text.BoundString(g.gameFont, "A").Dy()
+	y := windowHeight / 3
+	for i, line := range lines {
+		bounds := text.BoundString(g.gameFont, line)
+		x := (windowWidth - bounds.Dx()) / 2
+		text.Draw(screen, line, g.gameFont, x, y, color.White)
+		if i == 2 {
+			// The game font has no underscore, so the cursor is a block
+			ebitenutil.DrawRect(screen, float64(x+bounds.Dx()+2), float64(y-lineHeight), float64(lineHeight/2), float64(lineHeight), color.White)
+		}
+		if i == 3 {
+			g.drawNameWheel(screen, y)
+		}
+		y += lineHeight * 3
+	}
+}
+
+// readyMessage is the screen shown before each turn.
+func (g *Game) readyMessage() []string {
+	if ruleset.Name == rulesClassic {
+		return []string{tr("PLAY PLAYER %d", g.current+1)} // The game font has no angle brackets
+	}
+	if g.mode == modeCoop {
+		return []string{tr("PLAYER 1 AND 2"), tr("GET READY")}
+	}
+	if g.mode == modeVersus {
+		return []string{tr("ROUND %d", g.versus.round), g.versusStandings(), tr("GET READY")}
+	}
+	// The game font has no dash, so the name goes on its own line
+	return []string{tr("PLAYER %d", g.current+1), g.player().name, tr("GET READY")}
+}
+
+// playerScores is each player's name and score, for the HUD and game over screen.
+func (g *Game) playerScores() string {
+	var parts []string
+	for _, p := range g.players {
+		parts = append(parts, fmt.Sprintf("%s %s", p.name, number(p.score)))
+	}
+	return strings.Join(parts, "\n")
+}
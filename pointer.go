@@ -0,0 +1,160 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Control schemes, picked on the controls screen. The keys and gamepads always work,
+// the mouse and touch screen only in their own scheme.
+const (
+	schemeKeys  = "keys"
+	schemeMouse = "mouse" // The cannon chases the pointer, left click fires
+	schemeTouch = "touch" // On-screen buttons, see touchButtons
+)
+
+var controlSchemes = []string{schemeKeys, schemeMouse, schemeTouch}
+
+// pointerDeadband is how close in pixels the cannon has to be to the mouse pointer to stop chasing it.
+const pointerDeadband = 4
+
+// touchButtons are the on-screen buttons for the touch scheme: left and right in
+// the bottom left corner, fire in the bottom right and pause in the top right.
+// Positions are in the game's own coordinates, the same as ebiten gives touches in.
+func touchButtons() (left, right, fire, pause image.Rectangle) {
+	size := windowHeight / 6
+	bottom := windowHeight - ui(10)
+	left = image.Rect(ui(10), bottom-size, ui(10)+size, bottom)
+	right = left.Add(image.Pt(size+ui(10), 0))
+	fire = image.Rect(windowWidth-ui(10)-size*3/2, bottom-size, windowWidth-ui(10), bottom)
+	pause = image.Rect(windowWidth-ui(10)-size/2, ui(40), windowWidth-ui(10), ui(40)+size/2)
+	return
+}
+
+// pointerInput is what the mouse or touch screen is asking p's cannon to do.
+func (g *Game) pointerInput(p *Player) Input {
+	var in Input
+	switch config.ControlScheme {
+	case schemeMouse:
+		// The cursor in the game's own coordinates, so it lines up with the cannon
+		// whatever the window size, see logicalPosition
+		in |= g.steerTowards(p, cursorPosition().X)
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			in |= inputFire
+		}
+	case schemeTouch:
+		left, right, fire, _ := touchButtons()
+		for _, id := range ebiten.AppendTouchIDs(nil) {
+			touch := touchPosition(id)
+			if touch.In(left) {
+				in |= inputLeft
+			}
+			if touch.In(right) {
+				in |= inputRight
+			}
+		}
+		for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+			if touchPosition(id).In(fire) {
+				in |= inputFire
+			}
+		}
+	}
+	return in
+}
+
+// steerTowards is the direction to move p's cannon in to bring its middle to x.
+func (g *Game) steerTowards(p *Player, x int) Input {
+	dx := float64(x - (p.cannon.Position.X + p.cannon.size.Dx()/2))
+
+	// Let go early if the cannon would coast past x, so it doesn't wobble either
+	// side of it. The ship's top speed caps how fast it follows.
+	stopping := p.cannonVelocity * p.cannonVelocity / (2 * g.handling.Friction)
+	if math.Abs(dx) <= pointerDeadband || (dx*p.cannonVelocity > 0 && math.Abs(dx) <= stopping) {
+		return 0
+	}
+	if dx < 0 {
+		return inputLeft
+	}
+	return inputRight
+}
+
+// pointerJustPressed is whether the mouse or a touch did the action this tick.
+// Left click fires and right click confirms. On a touch screen the buttons do
+// what they say, and a tap anywhere else confirms.
+func pointerJustPressed(a Action) bool {
+	switch config.ControlScheme {
+	case schemeMouse:
+		switch a {
+		case actionFire:
+			return inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+		case actionConfirm:
+			return inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight)
+		}
+	case schemeTouch:
+		left, right, fire, pause := touchButtons()
+		for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+			touch := touchPosition(id)
+			switch {
+			case touch.In(left):
+				if a == actionMoveLeft {
+					return true
+				}
+			case touch.In(right):
+				if a == actionMoveRight {
+					return true
+				}
+			case touch.In(fire):
+				if a == actionFire {
+					return true
+				}
+			case touch.In(pause):
+				if a == actionPause {
+					return true
+				}
+			default:
+				if a == actionConfirm {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// readInput is everything p is pressing this tick: their keys and gamepad, plus
+// the mouse or touch screen if they play with it. With a one switch mode on, fire
+// is their switch and the game does the rest.
+func (g *Game) readInput(p *Player) Input {
+	in := p.controls.read()
+	if p.controls.pointer {
+		in |= g.pointerInput(p)
+	}
+	if config.OneSwitch != oneSwitchOff && g.assistAllowed() {
+		in = g.oneSwitchInput(p, in&inputFire != 0)
+	}
+	return in
+}
+
+// drawTouchButtons draws the touch scheme's buttons over the game.
+func (g *Game) drawTouchButtons(screen *ebiten.Image) {
+	if config.ControlScheme != schemeTouch {
+		return
+	}
+	left, right, fire, pause := touchButtons()
+	buttons := []struct {
+		rect  image.Rectangle
+		label string
+	}{{left, tr("Left")}, {right, tr("Right")}, {fire, tr("Fire")}, {pause, "II"}}
+	for _, button := range buttons {
+		r := button.rect
+		ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), color.RGBA{0xff, 0xff, 0xff, 0x30})
+		bounds := text.BoundString(g.gameFont, button.label)
+		text.Draw(screen, button.label, g.gameFont, r.Min.X+(r.Dx()-bounds.Dx())/2, r.Min.Y+(r.Dy()+bounds.Dy())/2, color.RGBA{0xff, 0xff, 0xff, 0xa0})
+	}
+}
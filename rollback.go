@@ -0,0 +1,246 @@
+package main
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// Online co-op doesn't wait for the other player's input like a LAN game does.
+// Each game guesses the other player is still doing what they last did and plays on.
+// When their real input turns out different, the world is rolled back to a snapshot
+// from before that frame and played forward again with what they actually pressed.
+const netMaxRollback = 8 // Frames we can guess ahead before waiting for the other player
+
+// worldSnapshot is everything the simulation changes, saved before each frame
+// so it can be rolled back.
+type worldSnapshot struct {
+	players []Player
+	boards  []Board
+	boardOf []int // Index into boards for each player, co-op players share one
+	loop    int
+	over    bool
+	current int
+
+	ufo           Sprite
+	ufoTimer      int
+	ufoDirection  int
+	ufoScore      int
+	ufoScoreX     int
+	ufoScoreTicks int
+	random        rand.PCG
+}
+
+// clone copies the board, including its own copy of every list the simulation edits in place.
+func (b Board) clone() Board {
+	b.aliens = slices.Clone(b.aliens)
+	b.barriers = slices.Clone(b.barriers)
+	b.bombs = slices.Clone(b.bombs)
+	b.explosions = slices.Clone(b.explosions)
+	b.attackers = slices.Clone(b.attackers)
+	return b
+}
+
+func (g *Game) snapshot() *worldSnapshot {
+	s := &worldSnapshot{
+		loop:          g.loop,
+		over:          g.gameOver,
+		current:       g.current,
+		ufo:           ufo,
+		ufoTimer:      g.ufoTimer,
+		ufoDirection:  g.ufoDirection,
+		ufoScore:      g.ufoScore,
+		ufoScoreX:     g.ufoScoreX,
+		ufoScoreTicks: g.ufoScoreTicks,
+		random:        *g.pcg,
+	}
+	index := map[*Board]int{}
+	for _, p := range g.players {
+		i, ok := index[p.board]
+		if !ok {
+			i = len(s.boards)
+			index[p.board] = i
+			s.boards = append(s.boards, p.board.clone())
+		}
+		player := *p
+		player.purchases = slices.Clone(p.purchases)
+		player.board = nil
+		s.players = append(s.players, player)
+		s.boardOf = append(s.boardOf, i)
+	}
+	return s
+}
+
+// restore puts the world back the way it was when s was taken. The players keep
+// their pointers, so nothing holding on to one is left behind.
+func (g *Game) restore(s *worldSnapshot) {
+	boards := make([]*Board, len(s.boards))
+	for i := range s.boards {
+		b := s.boards[i].clone()
+		boards[i] = &b
+	}
+	for i, p := range g.players {
+		*p = s.players[i]
+		p.purchases = slices.Clone(s.players[i].purchases)
+		p.board = boards[s.boardOf[i]]
+	}
+	g.loop = s.loop
+	g.gameOver = s.over
+	g.current = s.current
+	ufo = s.ufo
+	g.ufoTimer = s.ufoTimer
+	g.ufoDirection = s.ufoDirection
+	g.ufoScore = s.ufoScore
+	g.ufoScoreX = s.ufoScoreX
+	g.ufoScoreTicks = s.ufoScoreTicks
+	*g.pcg = s.random
+}
+
+// stepCoop plays one frame of online co-op with both players' inputs for it.
+func (g *Game) stepCoop(inputs [2]Input) {
+	for i, p := range g.players {
+		if p.lives > 0 {
+			g.applyInput(p, inputs[i])
+		}
+	}
+	g.step()
+}
+
+// updateRollback plays the next frame of online co-op straight away, and replays
+// any frames the other player's late input changed.
+func (g *Game) updateRollback() {
+	n := g.net
+	remote := 1 - n.local
+	rollbackFrom := -1
+	for _, m := range n.take() {
+		switch m.Type {
+		case msgHello:
+			if n.host {
+				n.send(n.welcome) // Our welcome got lost
+			}
+		case msgInput:
+			for i, in := range m.Inputs {
+				frame := m.Frame + i
+				if _, known := n.inputs[remote][frame]; known || frame <= n.remoteKnown {
+					continue
+				}
+				n.inputs[remote][frame] = in
+				if frame < n.frame && n.predicted[frame] != in && (rollbackFrom < 0 || frame < rollbackFrom) {
+					rollbackFrom = frame // We guessed wrong
+				}
+			}
+			for {
+				if _, ok := n.inputs[remote][n.remoteKnown+1]; !ok {
+					break
+				}
+				n.remoteKnown++
+			}
+			n.receiveChecksums(m)
+		case msgBye:
+			n.failure = "PARTNER LEFT"
+		}
+	}
+	if n.failure == "" && n.silence() > netTimeout {
+		n.failure = "CONNECTION LOST"
+	}
+	if n.failure != "" {
+		g.gameOver = true
+		return
+	}
+
+	if rollbackFrom >= 0 {
+		g.restore(n.snapshots[rollbackFrom])
+		end := n.frame
+		n.frame = rollbackFrom
+		g.resimulating = true // The sounds and popups were played the first time round
+		for n.frame < end && !g.gameOver {
+			g.playFrame(n.frame)
+			n.frame++
+		}
+		g.resimulating = false
+		n.rollbacks++
+	}
+
+	if n.nextInput <= n.frame+n.inputDelay {
+		n.inputs[n.local][n.nextInput] = g.readInput(g.players[n.local])
+		n.nextInput++
+	}
+	n.sendInputs()
+
+	// Guess ahead of the other player, but not so far that a rollback would be a long replay.
+	// Play an extra frame to catch up if they are ahead of us.
+	n.stalled = n.frame-n.remoteKnown > netMaxRollback
+	for played := 0; played < 2 && !n.stalled && !g.gameOver && n.frame < n.nextInput; played++ {
+		if played > 0 && n.frame > n.remoteKnown {
+			break
+		}
+		g.playFrame(n.frame)
+		n.frame++
+	}
+
+	// Frames both inputs have arrived for can't be rolled back any more, so their
+	// checksums go out with the next inputs, in order
+	for _, frame := range slices.Sorted(maps.Keys(n.pendingChecksums)) {
+		if frame <= n.remoteKnown {
+			n.checksums[n.local][frame] = n.pendingChecksums[frame]
+			n.compareChecksums(frame)
+			delete(n.pendingChecksums, frame)
+		}
+	}
+	for frame := range n.snapshots {
+		if frame <= n.remoteKnown {
+			delete(n.snapshots, frame)
+			delete(n.predicted, frame)
+		}
+	}
+	for i := range n.inputs {
+		for frame := range n.inputs[i] {
+			if frame < n.remoteKnown-2*netResendFrames {
+				delete(n.inputs[i], frame)
+			}
+		}
+	}
+	if n.failure != "" {
+		g.gameOver = true
+	}
+}
+
+// playFrame saves a snapshot and plays one frame with the other player's input
+// if it has arrived, or our guess at it if not.
+func (g *Game) playFrame(frame int) {
+	n := g.net
+	remote := 1 - n.local
+	n.snapshots[frame] = g.snapshot()
+
+	in, ok := n.inputs[remote][frame]
+	if !ok {
+		// They are probably still holding the same direction, but won't fire again straight away
+		in = n.inputs[remote][n.remoteKnown] &^ inputFire
+	}
+	n.predicted[frame] = in
+	var inputs [2]Input
+	inputs[n.local] = n.inputs[n.local][frame]
+	inputs[remote] = in
+	g.stepCoop(inputs)
+
+	// Only sent once both inputs are in for this frame and every one before it, as
+	// until then a rollback can still change it
+	if frame%netChecksumFrames == 0 {
+		n.pendingChecksums[frame] = g.checksum()
+	}
+}
+
+// settled is whether every frame played so far has both players' real input, so
+// the game over screen can't be taken back by a rollback.
+func (n *netSession) settled() bool {
+	return n.failure != "" || !n.rollback || n.remoteKnown >= n.frame-1
+}
+
+func (g *Game) rollbackStats() []string {
+	n := g.net
+	stats := []string{tr("DELAY %d  AHEAD %d  ROLLBACKS %d", n.inputDelay, max(0, n.frame-1-n.remoteKnown), n.rollbacks)}
+	if n.stalled {
+		stats = append(stats, tr("WAITING FOR THE OTHER PLAYER"))
+	}
+	return stats
+}
@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestSnapshotRestore(t *testing.T) {
+	inputs := [][2]Input{{inputLeft, inputRight}, {inputFire, 0}, {inputRight | inputFire, inputLeft}, {0, inputFire}}
+	play := func(g *Game, frames int) {
+		for frame := range frames {
+			g.stepCoop(inputs[frame%len(inputs)])
+		}
+	}
+	tests := []struct {
+		name string
+		mode int
+	}{
+		{"co-op", modeCoop},
+		{"solo", modeSolo},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newTestGame(t)
+			g.startGame(test.mode)
+			play(g, 30)
+			start := g.checksum()
+			s := g.snapshot()
+
+			play(g, 90)
+			after := g.checksum()
+			if after == start {
+				t.Fatal("90 frames didn't change the checksum")
+			}
+			g.players[0].purchases = append(g.players[0].purchases, Purchase{Wave: 1, Upgrade: "armour"})
+
+			g.restore(s)
+			if got := g.checksum(); got != start {
+				t.Fatalf("checksum after restore = %d, want %d", got, start)
+			}
+			if len(g.players[0].purchases) != 0 {
+				t.Errorf("purchases after restore = %v, want none", g.players[0].purchases)
+			}
+			if test.mode == modeCoop && g.players[0].board != g.players[1].board {
+				t.Error("co-op players no longer share a board")
+			}
+
+			// The same inputs from the snapshot play the same game, twice
+			play(g, 90)
+			if got := g.checksum(); got != after {
+				t.Errorf("checksum replaying from the snapshot = %d, want %d", got, after)
+			}
+			g.restore(s)
+			play(g, 90)
+			if got := g.checksum(); got != after {
+				t.Errorf("checksum replaying a second time = %d, want %d", got, after)
+			}
+		})
+	}
+}
+
+func TestChecksumChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(g *Game)
+	}{
+		{"score", func(g *Game) { g.players[0].score++ }},
+		{"lives", func(g *Game) { g.players[0].lives-- }},
+		{"cannon", func(g *Game) { g.players[0].cannonX += 0.5 }},
+		{"beam", func(g *Game) { g.players[0].beam.Status = !g.players[0].beam.Status }},
+		{"alien", func(g *Game) { g.players[0].board.aliens[3].Position.X++ }},
+		{"wave", func(g *Game) { g.players[0].board.wave++ }},
+		{"bomb", func(g *Game) { g.players[0].board.bombs = append(g.players[0].board.bombs, Sprite{}) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newTestGame(t)
+			before := g.checksum()
+			test.change(g)
+			if g.checksum() == before {
+				t.Errorf("changing the %s didn't change the checksum", test.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Names of the rulesets, used in the config file and with -rules.
+const (
+	rulesRelaxed = "relaxed" // the rules this game has always had
+	rulesClassic = "classic" // the original 1978 cabinet
+)
+
+// MarchSpeed is one row of a march speed table: with at most Aliens left,
+// the formation takes a step every Ticks ticks.
+type MarchSpeed struct {
+	Aliens int
+	Ticks  int
+}
+
+// ReloadSpeed is one row of a reload table: below Score points,
+// the aliens can fire a new bomb every Ticks ticks.
+type ReloadSpeed struct {
+	Score int
+	Ticks int
+}
+
+// Ruleset holds everything that differs between the relaxed and classic rules.
+type Ruleset struct {
+	Name string
+
+	// Screen
+	Width, Height int     // logical resolution
+	WindowScale   int     // window size is the logical resolution times this
+	SheetScale    float64 // sprite sheet art is drawn at 2x, 0.5 gives single pixel art
+	FontSize      float64
+	TitleFontSize float64
+	HUDFontSize   float64
+
+	// Formation
+	Rows, Columns          int
+	FormationX, FormationY int
+	ColumnSpacing          int
+	RowSpacing             int
+	RowPoints              []int        // points for each row, top row first
+	InvasionMargin         int          // aliens this close above the player have landed
+	MarchTable             []MarchSpeed // nil moves the formation every frame
+	MarchStep              int          // pixels per march step
+	MarchDrop              int          // pixels the formation drops at an edge
+	LastAlienStep          int          // right step of the very last alien, 0 for MarchStep
+
+	// Bombs
+	BombSpeed         int
+	ColumnFiringTable []int // 1-based columns, nil drops bombs at random
+	ReloadTable       []ReloadSpeed
+	MaxBombs          int
+
+	// Player
+	BarrierY, PlayerY int
+	Barriers          int
+	BeamStartY        int
+	Ship              *Ship      // fixed ship, nil to pick one on the ship select screen
+	Projectile        Projectile // beam of the fixed ship
+	ExtraLifeScore    int        // 0 for no extra life
+
+	// UFO
+	UFOValues        []int // score by shot count, nil for no UFO
+	UFOIntervalTicks int
+	UFOMinAliens     int
+	UFOY             int
+
+	// Screens and options
+	Shop            bool // open the upgrade shop between waves
+	Combo           bool // allow combo scoring
+	TwoPlayerPrompt bool // "PUSH 1 OR 2 PLAYERS BUTTON" before each game
+}
+
+var ruleset Ruleset
+
+// relaxedRules are built from the settings at the top of main.go.
+func relaxedRules() Ruleset {
+	return Ruleset{
+		Name:          rulesRelaxed,
+		Width:         windowWidth,
+		Height:        windowHeight,
+		WindowScale:   1,
+		SheetScale:    1,
+		FontSize:      24,
+		TitleFontSize: 28,
+		HUDFontSize:   16,
+
+		Rows:           5,
+		Columns:        12,
+		FormationX:     aliensStartCol,
+		FormationY:     30,
+		ColumnSpacing:  alienSize + 10,
+		RowSpacing:     30,
+		RowPoints:      []int{30, 20, 20, 10, 10},
+		InvasionMargin: 50,
+		MarchStep:      5,
+		MarchDrop:      10,
+
+		BombSpeed: bombSpeed,
+
+		BarrierY:   barrierYPosition,
+		PlayerY:    playerYPosition,
+		Barriers:   3,
+		BeamStartY: 250,
+
+		UFOY: 8, // Only flown in versus, see versus.go
+
+		Shop:  true,
+		Combo: true,
+	}
+}
+
+// classicShip is the laser base from the original cabinet: one speed, one shot on screen.
+var classicShip = Ship{
+	Name:       "Laser Base",
+	Sprite:     spriteRect{20, 47, 38, 59},
+	Explode:    spriteRect{0, 47, 16, 57},
+	Hitbox:     spriteRect{1, 4, 17, 12},
+	Handling:   Handling{Acceleration: 1e6, Friction: 1e6, MaxSpeed: 60}, // no inertia
+	Projectile: "classic",
+	Lives:      3,
+}
+
+var classicProjectile = Projectile{
+	Sprite: spriteRect{20, 60, 22, 65},
+	Speed:  240,
+}
+
+// classicRules reproduce the 1978 cabinet.
+func classicRules() Ruleset {
+	ship := classicShip
+	return Ruleset{
+		Name:          rulesClassic,
+		Width:         224,
+		Height:        256,
+		WindowScale:   3,
+		SheetScale:    0.5,
+		FontSize:      8,
+		TitleFontSize: 16,
+		HUDFontSize:   8,
+
+		Rows:           5,
+		Columns:        11,
+		FormationX:     24,
+		FormationY:     64,
+		ColumnSpacing:  16,
+		RowSpacing:     16,
+		RowPoints:      []int{30, 20, 20, 10, 10},
+		InvasionMargin: 8,
+		// The cabinet moves one alien per frame, so a full step takes as
+		// many frames as there are aliens left
+		MarchTable: []MarchSpeed{
+			{1, 1}, {2, 2}, {3, 3}, {4, 4}, {6, 6}, {8, 8}, {10, 10}, {15, 15},
+			{20, 20}, {25, 25}, {30, 30}, {35, 35}, {40, 40}, {45, 45}, {50, 50}, {55, 55},
+		},
+		MarchStep:     2,
+		MarchDrop:     8,
+		LastAlienStep: 3,
+
+		BombSpeed: 1,
+		// The plunger and squiggly shot column tables from the cabinet ROM
+		ColumnFiringTable: []int{
+			1, 7, 1, 1, 1, 4, 11, 1, 6, 3, 1, 1, 11, 9, 2, 8,
+			11, 1, 6, 3, 1, 1, 11, 9, 2, 8, 2, 11, 4, 7, 10,
+		},
+		ReloadTable: []ReloadSpeed{
+			{0x200, 48}, {0x1000, 16}, {0x2000, 11}, {0x3000, 8}, {math.MaxInt, 7},
+		},
+		MaxBombs: 3,
+
+		BarrierY:       192,
+		PlayerY:        216,
+		Barriers:       4,
+		BeamStartY:     216,
+		Ship:           &ship,
+		Projectile:     classicProjectile,
+		ExtraLifeScore: 1500,
+
+		UFOValues:        []int{100, 50, 50, 100, 150, 100, 100, 50, 300, 100, 100, 100, 50, 150, 100},
+		UFOIntervalTicks: 1536,
+		UFOMinAliens:     8,
+		UFOY:             40,
+
+		TwoPlayerPrompt: true,
+	}
+}
+
+// rulesetByName returns the named ruleset, falling back to the relaxed rules.
+func rulesetByName(name string) Ruleset {
+	switch name {
+	case rulesClassic:
+		return classicRules()
+	case rulesRelaxed, "":
+		return relaxedRules()
+	}
+	log.Printf("Unknown ruleset %q, using %q", name, rulesRelaxed)
+	return relaxedRules()
+}
+
+// applyRuleset copies the ruleset into the game settings. Call it before initGame.
+func applyRuleset(r Ruleset) {
+	ruleset = r
+	windowWidth = r.Width
+	windowHeight = r.Height
+	bombSpeed = r.BombSpeed
+	barrierYPosition = r.BarrierY
+	playerYPosition = r.PlayerY
+}
+
+// sheetRect scales a sprite sheet region to the ruleset's pixel size.
+func sheetRect(r image.Rectangle) image.Rectangle {
+	if ruleset.SheetScale == 1 {
+		return r
+	}
+	return image.Rect(scaled(r.Min.X), scaled(r.Min.Y), scaled(r.Max.X), scaled(r.Max.Y))
+}
+
+// scaled scales a sprite sheet distance to the ruleset's pixel size.
+func scaled(n int) int {
+	return int(math.Round(float64(n) * ruleset.SheetScale))
+}
+
+// scaleSheet redraws the sprite sheet at the ruleset's pixel size.
+func scaleSheet(sheet *ebiten.Image) *ebiten.Image {
+	if ruleset.SheetScale == 1 {
+		return sheet
+	}
+	bounds := sheetRect(sheet.Bounds())
+	scaledSheet := ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(ruleset.SheetScale, ruleset.SheetScale)
+	op.Filter = ebiten.FilterNearest
+	scaledSheet.DrawImage(sheet, op)
+	return scaledSheet
+}
+
+// marchTicks is how many ticks the formation waits between steps.
+func marchTicks(remaining int) int {
+	for _, speed := range ruleset.MarchTable {
+		if remaining <= speed.Aliens {
+			return speed.Ticks
+		}
+	}
+	return ruleset.MarchTable[len(ruleset.MarchTable)-1].Ticks
+}
+
+// reloadTicks is how many ticks the aliens wait between bombs at this score.
+func reloadTicks(score int) int {
+	for _, speed := range ruleset.ReloadTable {
+		if score < speed.Score {
+			return speed.Ticks
+		}
+	}
+	return ruleset.ReloadTable[len(ruleset.ReloadTable)-1].Ticks
+}
+
+// march moves the board's formation one step on the ruleset's march table.
+func (g *Game) march(b *Board) {
+	b.marchTimer++
+	remaining := b.aliveAliens()
+	if remaining == 0 || b.marchTimer < marchTicks(remaining) {
+		return
+	}
+	b.marchTimer = 0
+
+	step := ruleset.MarchStep
+	if remaining == 1 && b.alienDirection > 0 && ruleset.LastAlienStep > 0 {
+		step = ruleset.LastAlienStep
+	}
+	b.marchStep(step)
+}
+
+// marchStep moves the formation step pixels sideways,
+// or turns it around and drops it when a living alien reaches the edge.
+func (b *Board) marchStep(step int) {
+	for _, alien := range b.aliens {
+		if !alien.Status {
+			continue
+		}
+		x := alien.Position.X + step*b.alienDirection
+		if x < 8 || x+alien.size.Dx() > windowWidth-8 {
+			b.alienDirection *= -1
+			for i := range b.aliens {
+				b.aliens[i].Position.Y += ruleset.MarchDrop
+			}
+			return
+		}
+	}
+	for i := range b.aliens {
+		b.aliens[i].Position.X += step * b.alienDirection
+	}
+}
+
+// fireColumnBomb drops bombs from the columns in the firing table, in turn
+// with a rolling shot aimed at the column above one of the players.
+func (g *Game) fireColumnBomb(b *Board, players []*Player) {
+	b.reloadTimer++
+	if len(players) == 0 || float64(b.reloadTimer)*g.difficulty() < float64(reloadTicks(players[0].score)) || b.activeBombs() >= ruleset.MaxBombs {
+		return
+	}
+	b.reloadTimer = 0
+
+	// Every third shot rolls down the player's column
+	column := 0
+	b.shotType = (b.shotType + 1) % 3
+	if b.shotType == 0 {
+		// With two cannons on the screen the rolling shot picks on each in turn
+		target := players[b.columnIndex%len(players)].cannon
+		column = (target.Position.X-ruleset.FormationX)/ruleset.ColumnSpacing + 1
+	}
+
+	// Try each column from the table until one has an alien left in it
+	for tries := 0; tries < len(ruleset.ColumnFiringTable); tries++ {
+		if column == 0 {
+			column = ruleset.ColumnFiringTable[b.columnIndex]
+			b.columnIndex = (b.columnIndex + 1) % len(ruleset.ColumnFiringTable)
+		}
+		if alien, ok := b.lowestAlien(column - 1); ok {
+			b.dropBomb(alien)
+			return
+		}
+		column = 0
+	}
+}
+
+// lowestAlien is the living alien nearest the bottom of a formation column.
+func (b *Board) lowestAlien(column int) (Sprite, bool) {
+	lowest, found := Sprite{}, false
+	for i, alien := range b.aliens {
+		if alien.Status && i%ruleset.Columns == column && (!found || alien.Position.Y > lowest.Position.Y) {
+			lowest, found = alien, true
+		}
+	}
+	return lowest, found
+}
+
+func (b *Board) activeBombs() int {
+	count := 0
+	for _, bomb := range b.bombs {
+		if bomb.Status && bomb.Position.Y < windowHeight {
+			count++
+		}
+	}
+	return count
+}
+
+// checkExtraLife awards the ruleset's one extra life once the player's score is high enough.
+func (g *Game) checkExtraLife(p *Player) {
+	if ruleset.ExtraLifeScore > 0 && !p.extraLifeAwarded && p.score >= ruleset.ExtraLifeScore {
+		p.extraLifeAwarded = true
+		for _, teammate := range g.team(p) {
+			teammate.lives++
+		}
+	}
+}
+
+// Prompts shown in the middle of a game, instead of the playfield.
+const (
+	promptNone    = iota
+	promptReady   // PLAY PLAYER 1, or PLAYER 2 GET READY
+	promptNetwork // waiting for the other player in a LAN game
+)
+
+// promptReadyTicks is how long the get ready screen shows before a turn starts.
+const promptReadyTicks = 120
+
+// updatePlayersPrompt starts a one or two player game under rules with the
+// PUSH 1 OR 2 PLAYERS BUTTON prompt, see modeSelectScene.
+func (g *Game) updatePlayersPrompt() {
+	if inpututil.IsKeyJustPressed(ebiten.Key1) || justPressed(actionConfirm) {
+		g.startGame(modeSolo)
+		g.startTurn()
+		g.startPlaying()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.Key2) {
+		g.startGame(modeAlternating)
+		g.startPlaying()
+	}
+}
+
+func (g *Game) updatePrompt() {
+	switch g.prompt {
+	case promptReady:
+		g.promptTimer--
+		if g.promptTimer <= 0 {
+			g.prompt = promptNone
+		}
+	case promptNetwork:
+		g.updateHandshake()
+	}
+}
+
+func (g *Game) drawPrompt(screen *ebiten.Image) {
+	var lines []string
+	switch g.prompt {
+	case promptReady:
+		lines = g.readyMessage()
+	case promptNetwork:
+		// The game font has no dots or colons, so no addresses here
+		lines = []string{tr("CONNECTING"), tr("ESC TO GIVE UP")}
+		if g.net.host {
+			lines = []string{tr("WAITING FOR A PLAYER"), tr("ON PORT %d", g.net.port()), tr("ESC TO GIVE UP")}
+		}
+	}
+	g.drawLines(screen, lines)
+}
+
+// drawLines writes the lines down the middle of an empty screen.
+func (g *Game) drawLines(screen *ebiten.Image, lines []string) {
+	y := windowHeight / 3
+	for _, line := range lines {
+		bounds := text.BoundString(g.gameFont, line)
+		text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+		y += bounds.Dy() * 3
+	}
+}
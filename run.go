@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// A run is the record of a local game, enough for the simulation to play it again
+// exactly: the seed and settings it started with, what each player pressed on every
+// tick and what they bought in the shop. Every local game is saved as the last run,
+// next to the high scores, and go run . -replay files/lastrun.json plays it back.
+// Versus isn't recorded, as the invader's keys aren't inputs, and network games
+// have the other machine to agree with instead.
+const (
+	runVersion  = 1 // Bumped whenever a run plays differently, so old runs are refused
+	lastRunPath = "files/lastrun.json"
+)
+
+// Bits of a run's inputs besides the Input ones, for the Up and Down arrows that
+// move the cannons and barriers, see playTick.
+const (
+	runRaise Input = 1 << 6
+	runLower Input = 1 << 7
+)
+
+// Run is a recorded game, saved as JSON.
+type Run struct {
+	Version     int           `json:"version"`
+	Rules       string        `json:"rules"`
+	Ship        string        `json:"ship"`
+	Mode        int           `json:"mode"` // modeSolo, modeAlternating or modeCoop
+	Scoring     string        `json:"scoring"`
+	SharedLives bool          `json:"sharedLives,omitempty"`
+	PlayerY     int           `json:"playerY"` // Where the cannons started, as the arrows move them
+	Seed        uint64        `json:"seed"`
+	Settings    []runSettings `json:"settings"`  // From the first tick, and each tick they changed on
+	Inputs      []byte        `json:"inputs"`    // Each tick's Input for player 1 then player 2
+	Names       []string      `json:"names"`     // Each player's
+	Purchases   [][]Purchase  `json:"purchases"` // Each player's, in the order they were made
+	Scores      []int         `json:"scores"`    // Each player's at the end, to check a replay against
+}
+
+// runSettings is the settings that change how the game plays, from a tick of a run on.
+type runSettings struct {
+	Tick       int    `json:"tick"`
+	Difficulty string `json:"difficulty"`
+	NoDeath    bool   `json:"noDeath,omitempty"`
+}
+
+// newRun starts recording the game startGame has just set up with seed, or is
+// nil if the game can't be recorded.
+func (g *Game) newRun(seed uint64) *Run {
+	if g.mode == modeVersus || g.mode == modeLAN {
+		return nil
+	}
+	return &Run{
+		Version:     runVersion,
+		Rules:       ruleset.Name,
+		Ship:        g.ship.Name,
+		Mode:        g.mode,
+		Scoring:     g.scoring,
+		SharedLives: config.SharedLives,
+		PlayerY:     playerYPosition,
+		Seed:        seed,
+	}
+}
+
+func (r *Run) ticks() int {
+	return len(r.Inputs) / 2
+}
+
+// record adds a tick's inputs, by player number, and the settings if they have
+// changed since the last tick.
+func (r *Run) record(inputs [2]Input) {
+	settings := runSettings{Tick: r.ticks(), Difficulty: config.Difficulty, NoDeath: config.NoDeath}
+	if n := len(r.Settings); n == 0 || r.Settings[n-1].Difficulty != settings.Difficulty || r.Settings[n-1].NoDeath != settings.NoDeath {
+		r.Settings = append(r.Settings, settings)
+	}
+	r.Inputs = append(r.Inputs, byte(inputs[0]), byte(inputs[1]))
+}
+
+// saveRun finishes the run with the players' names, purchases and scores, and
+// saves it to path.
+func (g *Game) saveRun(path string) {
+	r := g.run
+	r.Names, r.Purchases, r.Scores = nil, nil, nil
+	for _, p := range g.players {
+		r.Names = append(r.Names, p.name)
+		r.Purchases = append(r.Purchases, slices.Clone(p.purchases))
+		r.Scores = append(r.Scores, p.score)
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		log.Println("Error saving the run:", err)
+		return
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		log.Println("Error saving the run:", err)
+	}
+}
+
+func loadRun(path string) (*Run, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Run{}
+	if err := json.Unmarshal(content, r); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if r.Version != runVersion {
+		return nil, fmt.Errorf("%s is from another version of the game (run version %d, this game plays %d)", path, r.Version, runVersion)
+	}
+	return r, nil
+}
+
+// replay is a run being played back, see replayScene.
+type replay struct {
+	run      *Run
+	tick     int  // The next tick to play
+	shopping bool // The wave has just been cleared, so the shop's purchases are next
+	saved    Config
+}
+
+// startReplay sets the game up to play r back, by the rules already applied.
+func (g *Game) startReplay(r *Run) error {
+	ship, ok := findShip(r.Ship)
+	switch {
+	case r.Rules != ruleset.Name:
+		return fmt.Errorf("run is for the %s rules, not %s", r.Rules, ruleset.Name)
+	case !ok:
+		return fmt.Errorf("run's ship %s isn't one of the ships", r.Ship)
+	case r.Mode != modeSolo && r.Mode != modeAlternating && r.Mode != modeCoop:
+		return errors.New("run has a mode that isn't recorded")
+	case len(r.Inputs)%2 != 0:
+		return errors.New("run's inputs are cut short")
+	}
+	players := 2
+	if r.Mode == modeSolo {
+		players = 1
+	}
+	if len(r.Names) != players || len(r.Purchases) != players || len(r.Scores) != players {
+		return errors.New("run has the wrong number of players for its mode")
+	}
+
+	// The replay plays with the run's settings, and puts the player's back at the end
+	g.replay = &replay{run: r, saved: config}
+	config.SharedLives = r.SharedLives
+	playerYPosition = r.PlayerY
+	g.applyShip(ship)
+	g.scoring = r.Scoring
+	g.startGame(r.Mode)
+	g.seedRandom(r.Seed)
+	g.run = nil // Not recorded again
+	for i, p := range g.players {
+		p.name = r.Names[i]
+	}
+	if len(g.players) > 1 || ruleset.TwoPlayerPrompt {
+		g.startTurn() // Get ready, as after the names or the players prompt
+	}
+	return nil
+}
+
+// replayTick plays the next tick of the replay, or of the get ready screen before
+// it. It returns false once the run has all been played, with an error if it
+// didn't play the same as when it was recorded.
+func (g *Game) replayTick() (bool, error) {
+	rp := g.replay
+	r := rp.run
+	if g.prompt != promptNone {
+		g.updatePrompt()
+		return true, nil
+	}
+	if rp.tick == r.ticks() {
+		for i, p := range g.players {
+			if p.score != r.Scores[i] {
+				return false, fmt.Errorf("player %d scored %d in the replay, not %d", i+1, p.score, r.Scores[i])
+			}
+		}
+		return false, nil
+	}
+	if g.gameOver {
+		return false, fmt.Errorf("game ended on tick %d of %d", rp.tick, r.ticks())
+	}
+
+	for _, settings := range r.Settings {
+		if settings.Tick == rp.tick {
+			config.Difficulty, config.NoDeath = settings.Difficulty, settings.NoDeath
+		}
+	}
+	g.playTick([2]Input{Input(r.Inputs[2*rp.tick]), Input(r.Inputs[2*rp.tick+1])})
+	rp.tick++
+	if rp.shopping {
+		rp.shopping = false
+		if err := g.replayShop(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// replayShop buys what was bought in the shop after the wave just cleared, by
+// whoever shopped there, and goes on to the next wave.
+func (g *Game) replayShop() error {
+	for _, p := range g.activePlayers() {
+		i := slices.Index(g.players, p)
+		for _, purchase := range g.replay.run.Purchases[i] {
+			if purchase.Wave != p.board.wave {
+				continue
+			}
+			upgrade, ok := findUpgrade(purchase.Upgrade)
+			if !ok || !p.buyUpgrade(upgrade) {
+				return fmt.Errorf("player %d can't buy %s after wave %d", i+1, purchase.Upgrade, purchase.Wave)
+			}
+		}
+	}
+	g.leaveShop()
+	return nil
+}
+
+// endReplay puts the player's own settings back.
+func (g *Game) endReplay() {
+	config.Difficulty = g.replay.saved.Difficulty
+	config.NoDeath = g.replay.saved.NoDeath
+	config.SharedLives = g.replay.saved.SharedLives
+	g.replay = nil
+}
+
+// updateReplay plays the replay on, a tick at a time, then shows the game over screen.
+func (g *Game) updateReplay() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.quit()
+	}
+	more, err := g.replayTick()
+	if err != nil {
+		log.Println("The replay went differently:", err)
+	}
+	if !more {
+		g.endReplay()
+		g.gameOver = true
+		g.switchScene(gameOverScene{})
+	}
+}
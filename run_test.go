@@ -0,0 +1,175 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// The demo's bot can't clear a wave before it lands, so the tests clear it for
+// them every so often, on the same ticks of the recording and the replay.
+const testClearTicks = 1500
+
+func clearWave(g *Game, tick int) {
+	if tick%testClearTicks != testClearTicks-1 {
+		return
+	}
+	for _, p := range g.activePlayers() {
+		for i := range p.board.aliens {
+			p.board.aliens[i].Status = false
+		}
+	}
+}
+
+// recordRun plays a game with the demo's bot at every player's controls, buying
+// the first upgrade each player can afford in every shop, and returns its run
+// once it has played ticks ticks or the game is over.
+func recordRun(t *testing.T, mode int, ticks int) (*Game, *Run) {
+	t.Helper()
+	g := newTestGame(t)
+	config.NoDeath = true // So the bot lives to see the shop
+	g.startGame(mode)
+	for g.run.ticks() < ticks && !g.gameOver {
+		if g.prompt != promptNone {
+			g.updatePrompt()
+			continue
+		}
+		if g.run.ticks() == ticks/2 {
+			config.Difficulty = difficultyHard
+		}
+		var inputs [2]Input
+		for _, p := range g.activePlayers() {
+			inputs[slices.Index(g.players, p)] = g.botInput(p)
+		}
+		clearWave(g, g.run.ticks())
+		g.run.record(inputs)
+		g.playTick(inputs)
+
+		// Like waveClearScene, without the scenes
+		if len(g.fadeChanges) > 0 {
+			g.fadeChanges, g.fadeTimer = nil, 0
+			for _, p := range g.activePlayers() {
+				for _, upgrade := range upgrades {
+					if p.buyUpgrade(upgrade) {
+						break
+					}
+				}
+			}
+			g.leaveShop()
+		}
+	}
+	return g, g.run
+}
+
+// playReplay plays g's replay to the end, or until it goes differently.
+func playReplay(g *Game) error {
+	for {
+		if g.prompt == promptNone {
+			clearWave(g, g.replay.tick)
+		}
+		more, err := g.replayTick()
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	newTestGame(t)
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	tests := []struct {
+		name string
+		mode int
+	}{
+		{"solo", modeSolo},
+		{"alternating", modeAlternating},
+		{"co op", modeCoop},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config = saved
+			played, r := recordRun(t, test.mode, 6000)
+			path := filepath.Join(t.TempDir(), "run.json")
+			played.saveRun(path)
+			bought := 0
+			for _, purchases := range r.Purchases {
+				bought += len(purchases)
+			}
+			if bought == 0 || len(r.Settings) != 2 {
+				t.Fatalf("Recorded %d purchases and %d settings, want some of each", bought, len(r.Settings))
+			}
+
+			loaded, err := loadRun(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config = saved
+			g := newTestGame(t)
+			if err := g.startReplay(loaded); err != nil {
+				t.Fatal(err)
+			}
+			if err := playReplay(g); err != nil {
+				t.Fatal(err)
+			}
+			if g.checksum() != played.checksum() {
+				t.Errorf("Replay ended in a different world")
+			}
+			g.endReplay()
+			if config.NoDeath || config.Difficulty != saved.Difficulty {
+				t.Errorf("Replay left its settings behind")
+			}
+		})
+	}
+}
+
+func TestReplayChanged(t *testing.T) {
+	newTestGame(t)
+	saved := config
+	t.Cleanup(func() { config = saved })
+	played, r := recordRun(t, modeSolo, 6000)
+	played.saveRun(filepath.Join(t.TempDir(), "run.json"))
+	config = saved
+
+	tests := []struct {
+		name   string
+		change func(r *Run)
+		start  bool // Whether startReplay takes it
+	}{
+		{"score", func(r *Run) { r.Scores[0]++ }, true},
+		{"input", func(r *Run) {
+			for i := range r.Inputs {
+				r.Inputs[i] = 0
+			}
+		}, true},
+		{"purchase", func(r *Run) { r.Purchases[0] = append(r.Purchases[0], Purchase{Wave: 1, Upgrade: "nothing"}) }, true},
+		{"rules", func(r *Run) { r.Rules = rulesClassic }, false},
+		{"ship", func(r *Run) { r.Ship = "nothing" }, false},
+		{"versus", func(r *Run) { r.Mode = modeVersus }, false},
+		{"players", func(r *Run) { r.Mode = modeCoop }, false},
+		{"inputs cut short", func(r *Run) { r.Inputs = r.Inputs[:len(r.Inputs)-1] }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := *r
+			changed.Inputs = append([]byte(nil), r.Inputs...)
+			changed.Scores = append([]int(nil), r.Scores...)
+			changed.Purchases = [][]Purchase{append([]Purchase(nil), r.Purchases[0]...)}
+			test.change(&changed)
+
+			g := newTestGame(t)
+			err := g.startReplay(&changed)
+			if (err == nil) != test.start {
+				t.Fatalf("startReplay() = %v, want it to start %v", err, test.start)
+			}
+			if err != nil {
+				return
+			}
+			defer g.endReplay()
+			if playReplay(g) == nil {
+				t.Error("Replay played the same")
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// A scene is one screen of the game. Scenes sit on a stack and only the top one
+// is updated and drawn. Paused, Settings, Controls and WaveClear go on top of the
+// scene they were opened from and come off it again, so the game carries on where
+// it left off.
+// Every other change swaps the whole stack for a new scene.
+type scene interface {
+	enter(g *Game) // It has just become the top scene, pushed or switched to
+	exit(g *Game)  // It is being popped or switched away from, not covered up
+	update(g *Game)
+	draw(g *Game, screen *ebiten.Image)
+}
+
+// fadeTicks is how long the screen takes to fade to black, and again to fade back in.
+const fadeTicks = 12
+
+// scene is the scene on top of the stack.
+func (g *Game) scene() scene {
+	return g.scenes[len(g.scenes)-1]
+}
+
+// pushScene puts s on top of the current scene.
+func (g *Game) pushScene(s scene) {
+	g.transition(func() {
+		g.scenes = append(g.scenes, s)
+		s.enter(g)
+	})
+}
+
+// popScene goes back to the scene underneath.
+func (g *Game) popScene() {
+	g.transition(func() {
+		g.scene().exit(g)
+		g.scenes = g.scenes[:len(g.scenes)-1]
+	})
+}
+
+// switchScene leaves every scene on the stack, top first, and starts again with s.
+func (g *Game) switchScene(s scene) {
+	g.transition(func() {
+		for i := len(g.scenes) - 1; i >= 0; i-- {
+			g.scenes[i].exit(g)
+		}
+		g.scenes = []scene{s}
+		s.enter(g)
+	})
+}
+
+// transition fades the screen out, makes the change to the stack while it is black,
+// and fades back in. Changes asked for while fading out happen together, in order.
+func (g *Game) transition(change func()) {
+	if g.fadeTimer > fadeTicks {
+		g.fadeChanges = append(g.fadeChanges, change)
+		return
+	}
+	// Fading back in already, so turn round from however dark it is now
+	g.fadeChanges = []func(){change}
+	g.fadeTimer = 2*fadeTicks - g.fadeTimer
+}
+
+// updateScenes runs the top scene. Nothing moves while the screen fades out, so
+// the scene being left can't change the stack again; the new scene starts as soon
+// as it has been entered.
+func (g *Game) updateScenes() {
+	if g.fadeTimer > 0 {
+		g.fadeTimer--
+		if g.fadeTimer == fadeTicks {
+			changes := g.fadeChanges
+			g.fadeChanges = nil
+			for _, change := range changes {
+				change()
+			}
+		}
+		if g.fadeTimer >= fadeTicks {
+			return
+		}
+	}
+	g.scene().update(g)
+}
+
+// drawScenes draws the top scene, darkened by the fade between scenes.
+func (g *Game) drawScenes(screen *ebiten.Image) {
+	g.scene().draw(g, screen)
+	if g.fadeTimer == 0 {
+		return
+	}
+	dark := float64(g.fadeTimer) / fadeTicks // Fading in
+	if g.fadeTimer > fadeTicks {
+		dark = float64(2*fadeTicks-g.fadeTimer) / fadeTicks // Fading out
+	}
+	ebitenutil.DrawRect(screen, 0, 0, float64(windowWidth), float64(windowHeight), color.RGBA{0, 0, 0, uint8(dark * 0xff)})
+}
+
+// drawBelow draws the scene underneath the top one, for the scenes that go over the game.
+func (g *Game) drawBelow(screen *ebiten.Image) {
+	if len(g.scenes) > 1 {
+		g.scenes[len(g.scenes)-2].draw(g, screen)
+	}
+}
+
+// newGame goes from the title or the game over screen to a new game, by way of
+// the ship select or the players prompt if the rules have one.
+func (g *Game) newGame() {
+	if ruleset.TwoPlayerPrompt || ruleset.Ship == nil {
+		g.switchScene(modeSelectScene{})
+		return
+	}
+	g.startGame(g.mode)
+	g.startPlaying()
+}
+
+// startPlaying starts the game startGame set up, once two players have typed their names.
+func (g *Game) startPlaying() {
+	if len(g.players) > 1 && g.mode != modeLAN {
+		g.switchScene(nameEntryScene{})
+		return
+	}
+	g.switchScene(playingScene{})
+}
+
+// titleScene is the start screen the game opens on. Left alone it turns into
+// the attract mode, see attract.go.
+type titleScene struct{}
+
+func (titleScene) enter(g *Game) {
+	g.titleTimer = 0
+}
+
+func (titleScene) exit(g *Game) {}
+
+func (titleScene) update(g *Game) {
+	// The picture says S, the confirm and fire buttons work too
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) || justPressed(actionConfirm) || justPressed(actionFire) {
+		g.newGame()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.pushScene(controlsScene{})
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+		g.pushScene(settingsScene{})
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.quit()
+	}
+	g.updateTitle()
+}
+
+func (titleScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawTitle(screen)
+
+	help := tr("O for settings  K for controls  Esc to quit")
+	bounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight-ui(40), color.White)
+}
+
+// modeSelectScene picks the players and ship: the ship select screen, or the
+// players prompt under rules with a fixed ship.
+type modeSelectScene struct{}
+
+func (modeSelectScene) enter(g *Game) {}
+func (modeSelectScene) exit(g *Game)  {}
+
+func (modeSelectScene) update(g *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.switchScene(titleScene{})
+		return
+	}
+	if ruleset.TwoPlayerPrompt {
+		g.updatePlayersPrompt()
+		return
+	}
+	g.updateShipSelect()
+}
+
+func (modeSelectScene) draw(g *Game, screen *ebiten.Image) {
+	if ruleset.TwoPlayerPrompt {
+		g.drawLines(screen, []string{tr("PUSH"), tr("1 OR 2 PLAYERS"), tr("BUTTON")})
+		return
+	}
+	g.drawShipSelect(screen)
+}
+
+// nameEntryScene has each player type their name before a two player game.
+type nameEntryScene struct{}
+
+func (nameEntryScene) enter(g *Game) {
+	g.naming = 0
+	g.nameInput = []rune(g.players[0].name)
+	g.wheelIndex = 0
+}
+
+func (nameEntryScene) exit(g *Game) {}
+
+func (nameEntryScene) update(g *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.switchScene(titleScene{})
+		return
+	}
+	g.updateNameEntry()
+}
+
+func (nameEntryScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawNameEntry(screen)
+}
+
+// playingScene is the game itself, including the get ready screen between turns
+// and waiting for the other player in a network game. restart starts the game
+// being played again, from the pause menu.
+type playingScene struct {
+	restart bool
+}
+
+func (s playingScene) enter(g *Game) {
+	if s.restart {
+		g.restartGame()
+	}
+}
+
+func (playingScene) exit(g *Game) {}
+
+func (playingScene) update(g *Game) {
+	g.updatePlaying()
+}
+
+func (playingScene) draw(g *Game, screen *ebiten.Image) {
+	if g.prompt != promptNone {
+		g.drawPrompt(screen)
+		return
+	}
+	g.drawGameScreen(screen)
+	g.drawTouchButtons(screen)
+}
+
+// pausedScene is the pause menu, over the game. The game underneath isn't updated
+// at all while it shows.
+type pausedScene struct{}
+
+func (pausedScene) enter(g *Game) {
+	g.pauseIndex = pauseResume
+	g.confirmingQuit = false
+	if backgroundSound != nil {
+		backgroundSound.Pause()
+	}
+}
+
+func (pausedScene) exit(g *Game) {
+	if backgroundSound != nil {
+		backgroundSound.Play()
+	}
+}
+
+func (pausedScene) update(g *Game) {
+	g.updatePauseMenu()
+}
+
+func (pausedScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawBelow(screen)
+	g.drawPauseMenu(screen)
+}
+
+// waveClearScene is the shop between waves. Leaving it starts the next wave.
+type waveClearScene struct{}
+
+func (waveClearScene) enter(g *Game) {
+	g.shopIndex = 0
+	if g.mode == modeCoop {
+		g.current = -1
+		g.nextShopper()
+	}
+}
+
+func (waveClearScene) exit(g *Game) {
+	g.leaveShop()
+}
+
+func (waveClearScene) update(g *Game) {
+	g.updateShop()
+}
+
+func (waveClearScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawShop(screen)
+}
+
+// highScoreEntryScene asks for a name for each score from the game just ended
+// that is good enough for the leaderboard, then goes on to next.
+type highScoreEntryScene struct {
+	next scene
+}
+
+func (highScoreEntryScene) enter(g *Game) {
+	g.nextPendingScore() // recordScores only comes here with one
+}
+
+func (highScoreEntryScene) exit(g *Game) {}
+
+func (s highScoreEntryScene) update(g *Game) {
+	g.updateHighScoreEntry(s.next)
+}
+
+func (highScoreEntryScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawHighScoreEntry(screen)
+}
+
+// gameOverScene shows the scores at the end of a game, after any high scores
+// have been named, see recordScores.
+type gameOverScene struct{}
+
+func (gameOverScene) enter(g *Game) {
+	g.gameOverTimer = 0
+	g.showGameOverText = true
+}
+
+func (gameOverScene) exit(g *Game) {
+	if gameOverSound != nil {
+		gameOverSound.Pause()
+	}
+}
+
+func (gameOverScene) update(g *Game) {
+	g.updateGameOver()
+}
+
+func (gameOverScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawGameOverScreen(screen)
+}
+
+// settingsScene is the settings panel, over whichever scene it was opened from.
+type settingsScene struct{}
+
+func (settingsScene) enter(g *Game) {
+	g.settingsIndex = 0
+}
+
+func (settingsScene) exit(g *Game) {}
+
+func (settingsScene) update(g *Game) {
+	g.updateSettings()
+}
+
+func (settingsScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawSettings(screen)
+}
+
+// controlsScene is the controls screen, opened from the settings panel, the pause
+// menu or the title.
+type controlsScene struct{}
+
+func (controlsScene) enter(g *Game) {
+	g.bindingIndex = 0
+	g.capturingKey = false
+	g.bindingMessage = ""
+}
+
+func (controlsScene) exit(g *Game) {}
+
+func (controlsScene) update(g *Game) {
+	g.updateKeyBindings()
+}
+
+func (controlsScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawKeyBindings(screen)
+}
+
+// replayScene plays back a run saved by an earlier game, see run.go.
+type replayScene struct{}
+
+func (replayScene) enter(g *Game) {}
+func (replayScene) exit(g *Game)  {}
+
+func (replayScene) update(g *Game) {
+	g.updateReplay()
+}
+
+func (replayScene) draw(g *Game, screen *ebiten.Image) {
+	if g.prompt != promptNone {
+		g.drawPrompt(screen)
+		return
+	}
+	g.drawGameScreen(screen)
+}
+
+// spectatingScene watches a game streamed from another computer, see spectate.go.
+type spectatingScene struct{}
+
+func (spectatingScene) enter(g *Game) {}
+func (spectatingScene) exit(g *Game)  {}
+
+func (spectatingScene) update(g *Game) {
+	g.updateSpectating()
+}
+
+func (spectatingScene) draw(g *Game, screen *ebiten.Image) {
+	g.drawSpectating(screen)
+}
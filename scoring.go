@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Scoring rules a game can be played under. The name is saved with each high score.
+const (
+	scoringStandard = "standard" // every alien is worth its points
+	scoringCombo    = "combo"    // a streak of hits raises a score multiplier
+)
+
+const (
+	comboStep          = 4  // streak needed for each extra multiplier step
+	comboQuickKillTick = 45 // a kill this soon after the last one counts twice towards the streak
+	maxMultiplier      = 8
+	popupTicks         = 45 // how long a score popup floats for
+)
+
+// scorePopup is a floating score shown where an alien was hit.
+type scorePopup struct {
+	x, y   int
+	text   string
+	ticks  int
+	colour color.RGBA // Colour of the player who scored it
+}
+
+// multiplier is the player's current score multiplier, always 1 under standard scoring.
+func (g *Game) multiplier(p *Player) int {
+	if g.scoring != scoringCombo {
+		return 1
+	}
+	return min(1+p.comboStreak/comboStep, maxMultiplier)
+}
+
+// awardKill scores an alien shot by the player, adds to their combo and shows a popup.
+func (g *Game) awardKill(p *Player, alien Sprite) {
+	multiplier := g.multiplier(p)
+	points := alien.Points * multiplier
+	p.score += points
+	p.credits += alien.Points / pointsPerCredit
+	p.beamHits++
+
+	if g.scoring == scoringCombo {
+		if p.comboStreak > 0 && g.loop-p.lastKillLoop <= comboQuickKillTick {
+			p.comboStreak += 2
+		} else {
+			p.comboStreak++
+		}
+	}
+	p.lastKillLoop = g.loop
+
+	popup := fmt.Sprintf("%d", points)
+	if multiplier > 1 {
+		popup = fmt.Sprintf("%d x%d", alien.Points, multiplier)
+	}
+	if g.resimulating {
+		return // Already shown the first time the frame was played
+	}
+	g.popups = append(g.popups, scorePopup{x: alien.Position.X, y: alien.Position.Y, text: popup, ticks: popupTicks, colour: p.colour})
+}
+
+// breakCombo resets the player's multiplier after a missed shot or a lost life.
+func (g *Game) breakCombo(p *Player) {
+	p.comboStreak = 0
+}
+
+// updatePopups ages the score popups a tick, in the simulation rather than in
+// Draw so they stop while the game is paused.
+func (g *Game) updatePopups() {
+	if g.resimulating {
+		return // The popups weren't made again either
+	}
+	popups := g.popups[:0]
+	for _, popup := range g.popups {
+		popup.ticks--
+		if popup.ticks > 0 {
+			popups = append(popups, popup)
+		}
+	}
+	g.popups = popups
+}
+
+// drawPopups floats each score popup upwards until it runs out of time.
+func (g *Game) drawPopups(screen *ebiten.Image) {
+	for _, popup := range g.popups {
+		y := popup.y - (popupTicks - popup.ticks)
+		alpha := uint8(255 * popup.ticks / popupTicks)
+		r, green, b, _ := paletteColour(popup.colour, y).RGBA()
+		text.Draw(screen, popup.text, g.gameFont, popup.x, y, color.NRGBA{uint8(r >> 8), uint8(green >> 8), uint8(b >> 8), alpha})
+	}
+}
+
+// toggleScoring switches between standard and combo scoring.
+func (g *Game) toggleScoring() {
+	if g.scoring == scoringCombo {
+		g.scoring = scoringStandard
+	} else {
+		g.scoring = scoringCombo
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestMultiplier(t *testing.T) {
+	tests := []struct {
+		scoring string
+		streak  int
+		want    int
+	}{
+		{scoringStandard, 0, 1},
+		{scoringStandard, 100, 1},
+		{scoringCombo, 0, 1},
+		{scoringCombo, comboStep - 1, 1},
+		{scoringCombo, comboStep, 2},
+		{scoringCombo, comboStep*3 + 1, 4},
+		{scoringCombo, comboStep * 100, maxMultiplier},
+	}
+	for _, test := range tests {
+		g := &Game{scoring: test.scoring}
+		if got := g.multiplier(&Player{comboStreak: test.streak}); got != test.want {
+			t.Errorf("%s scoring, streak %d: multiplier = %d, want %d", test.scoring, test.streak, got, test.want)
+		}
+	}
+}
+
+func TestAwardKill(t *testing.T) {
+	tests := []struct {
+		name       string
+		scoring    string
+		streak     int
+		sinceLast  int // Ticks since the player's last kill
+		wantScore  int
+		wantStreak int
+		wantPopup  string
+	}{
+		{"standard", scoringStandard, 0, 1000, 30, 0, "30"},
+		{"first combo kill", scoringCombo, 0, 0, 30, 1, "30"},
+		{"slow kill", scoringCombo, 1, comboQuickKillTick + 1, 30, 2, "30"},
+		{"quick kill counts twice", scoringCombo, 1, comboQuickKillTick, 30, 3, "30"},
+		{"multiplied", scoringCombo, comboStep, 1000, 60, comboStep + 1, "30 x2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &Game{scoring: test.scoring, loop: 1000}
+			p := &Player{comboStreak: test.streak, lastKillLoop: g.loop - test.sinceLast}
+			g.awardKill(p, Sprite{Points: 30})
+			if p.score != test.wantScore {
+				t.Errorf("score = %d, want %d", p.score, test.wantScore)
+			}
+			if p.comboStreak != test.wantStreak {
+				t.Errorf("streak = %d, want %d", p.comboStreak, test.wantStreak)
+			}
+			if p.credits != 30/pointsPerCredit {
+				t.Errorf("credits = %d, want %d", p.credits, 30/pointsPerCredit)
+			}
+			if len(g.popups) != 1 || g.popups[0].text != test.wantPopup {
+				t.Errorf("popups = %+v, want one saying %q", g.popups, test.wantPopup)
+			}
+		})
+	}
+}
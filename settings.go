@@ -0,0 +1,272 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// setting is a row on the settings panel or the controls screen, changed with Left and Right.
+type setting struct {
+	name   string // In English, see tr
+	value  func() string
+	change func(step int) // step is 1 for Right and -1 for Left
+	level  func() float64 // How full to draw the slider, from 0 to 1, for settings that are one
+	open   func(g *Game)  // Confirm opens another screen instead, for rows with no value
+}
+
+// Difficulties scale how often the aliens bomb and how fast the bombs fall.
+const (
+	difficultyEasy   = "easy"
+	difficultyNormal = "normal"
+	difficultyHard   = "hard"
+)
+
+var difficulties = []string{difficultyEasy, difficultyNormal, difficultyHard}
+
+var difficultyScales = map[string]float64{
+	difficultyEasy:   0.6,
+	difficultyNormal: 1,
+	difficultyHard:   1.5,
+}
+
+const (
+	defaultVolume  = 0.5
+	volumeStep     = 0.1
+	maxWindowScale = 4
+)
+
+// settingsPanel is every row on the settings panel, in order.
+var settingsPanel = []setting{
+	{name: "Music volume", value: func() string { return percent(config.MusicVolume) }, level: func() float64 { return config.MusicVolume }, change: func(step int) {
+		config.MusicVolume = stepVolume(config.MusicVolume, step)
+		applyVolumes()
+	}},
+	{name: "Sound effects volume", value: func() string { return percent(config.SoundVolume) }, level: func() float64 { return config.SoundVolume }, change: func(step int) {
+		config.SoundVolume = stepVolume(config.SoundVolume, step)
+		applyVolumes()
+	}},
+	{name: "Difficulty", value: func() string { return tr(config.Difficulty) }, change: func(step int) {
+		config.Difficulty = cycle(difficulties, config.Difficulty, step)
+	}},
+	{name: "Fullscreen", value: func() string { return onOff(config.Fullscreen) }, change: func(int) {
+		config.Fullscreen = !config.Fullscreen
+		applyDisplay()
+	}},
+	{name: "Window scale", value: windowScaleName, change: func(step int) {
+		config.WindowScale = (config.WindowScale + step + maxWindowScale + 1) % (maxWindowScale + 1)
+		config.WindowWidth, config.WindowHeight = 0, 0 // Forget the size it was dragged to
+		applyDisplay()
+	}},
+	{name: "Scaling", value: func() string { return tr(config.Scaling) }, change: func(step int) {
+		config.Scaling = cycle(scalingModes, config.Scaling, step)
+	}},
+	{name: "Language", value: languageName, change: func(step int) {
+		config.Language = cycle(languageOptions(), config.Language, step)
+		applyLanguage()
+	}},
+	{name: "Palette", value: func() string { return tr(config.Palette) }, change: func(step int) {
+		config.Palette = cycle(paletteNames, config.Palette, step)
+	}},
+	{name: "Background", value: func() string { return tr(config.Background) }, change: func(step int) {
+		config.Background = cycle(backgrounds, config.Background, step)
+	}},
+	{name: "Reduced motion", value: func() string { return onOff(config.ReducedMotion) }, change: func(int) {
+		config.ReducedMotion = !config.ReducedMotion
+	}},
+	{name: "Controls", open: func(g *Game) { g.pushScene(controlsScene{}) }},
+	{name: "One switch", value: func() string { return tr(config.OneSwitch) }, change: func(step int) {
+		config.OneSwitch = cycle(oneSwitchModes, config.OneSwitch, step)
+	}},
+	{name: "Game speed", value: func() string { return percent(config.GameSpeed) }, level: func() float64 { return config.GameSpeed }, change: func(step int) {
+		config.GameSpeed = math.Round(min(1, max(minGameSpeed, config.GameSpeed+float64(step)*gameSpeedStep))*100) / 100
+	}},
+	{name: "No death", value: func() string { return onOff(config.NoDeath) }, change: func(int) {
+		config.NoDeath = !config.NoDeath
+	}},
+}
+
+// resetSettings puts the settings panel's settings back to their defaults. The
+// controls have their own reset, on the controls screen.
+func resetSettings() {
+	defaults := defaultConfig()
+	config.MusicVolume = defaults.MusicVolume
+	config.SoundVolume = defaults.SoundVolume
+	config.Difficulty = defaults.Difficulty
+	config.Fullscreen = defaults.Fullscreen
+	config.WindowScale = defaults.WindowScale
+	config.WindowWidth, config.WindowHeight = defaults.WindowWidth, defaults.WindowHeight
+	config.Scaling = defaults.Scaling
+	config.Language = defaults.Language
+	config.Palette = defaults.Palette
+	config.Background = defaults.Background
+	config.ReducedMotion = defaults.ReducedMotion
+	config.OneSwitch = defaults.OneSwitch
+	config.GameSpeed = defaults.GameSpeed
+	config.NoDeath = defaults.NoDeath
+	applyVolumes()
+	applyDisplay()
+	applyLanguage()
+}
+
+// checkSettings keeps the settings panel's settings from the config file in range.
+func checkSettings() {
+	config.MusicVolume = min(1, max(0, config.MusicVolume))
+	config.SoundVolume = min(1, max(0, config.SoundVolume))
+	if !slices.Contains(difficulties, config.Difficulty) {
+		config.Difficulty = difficultyNormal
+	}
+	config.WindowScale = min(maxWindowScale, max(0, config.WindowScale))
+	config.WindowWidth, config.WindowHeight = max(0, config.WindowWidth), max(0, config.WindowHeight)
+	if !slices.Contains(scalingModes, config.Scaling) {
+		config.Scaling = scalingFit
+	}
+	if !slices.Contains(paletteNames, config.Palette) {
+		config.Palette = paletteNormal
+	}
+	if !slices.Contains(backgrounds, config.Background) {
+		config.Background = backgroundNormal
+	}
+	if !slices.Contains(oneSwitchModes, config.OneSwitch) {
+		config.OneSwitch = oneSwitchOff
+	}
+	config.GameSpeed = min(1, max(minGameSpeed, config.GameSpeed))
+}
+
+// difficulty is how much more the aliens bomb than the rules say. A network game
+// plays on the normal difficulty, so both machines play the same game.
+func (g *Game) difficulty() float64 {
+	if g.net != nil {
+		return 1
+	}
+	return difficultyScales[config.Difficulty]
+}
+
+// bombStep is how far a bomb falls in one tick.
+func (g *Game) bombStep() int {
+	return max(1, int(math.Round(float64(bombSpeed)*g.difficulty())))
+}
+
+// applyVolumes sets the music and the sound effects to their volume settings.
+func applyVolumes() {
+	for _, music := range []*audio.Player{backgroundSound, gameOverSound, endGameSound} {
+		if music != nil {
+			music.SetVolume(config.MusicVolume)
+		}
+	}
+	for _, sound := range []*audio.Player{laserSound, explosionSound, shipExplosionSound} {
+		if sound != nil {
+			sound.SetVolume(config.SoundVolume)
+		}
+	}
+}
+
+func stepVolume(volume float64, step int) float64 {
+	return math.Round(min(1, max(0, volume+float64(step)*volumeStep))*100) / 100
+}
+
+func percent(fraction float64) string {
+	return tr("%d percent", int(math.Round(fraction*100)))
+}
+
+// cycle is the option step places along from current, going round at the ends.
+func cycle(options []string, current string, step int) string {
+	i := slices.Index(options, current)
+	return options[(i+step+len(options))%len(options)]
+}
+
+func onOff(on bool) string {
+	if on {
+		return tr("on")
+	}
+	return tr("off")
+}
+
+// updateSettings moves around the settings panel, see settingsScene. Every change
+// takes effect and is saved straight away.
+func (g *Game) updateSettings() {
+	if menuDown() {
+		g.settingsIndex = (g.settingsIndex + 1) % len(settingsPanel)
+	}
+	if menuUp() {
+		g.settingsIndex = (g.settingsIndex + len(settingsPanel) - 1) % len(settingsPanel)
+	}
+	s := settingsPanel[g.settingsIndex]
+	if s.open != nil {
+		if justPressed(actionConfirm) {
+			s.open(g)
+			return
+		}
+	} else if step := menuStep(); step != 0 {
+		s.change(step)
+		saveConfig()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		resetSettings()
+		saveConfig()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.popScene()
+	}
+}
+
+// menuStep is 1 for Right and -1 for Left, with the arrow keys or the d-pad.
+func menuStep() int {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || padJustPressed(ebiten.StandardGamepadButtonLeftRight):
+		return 1
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || padJustPressed(ebiten.StandardGamepadButtonLeftLeft):
+		return -1
+	}
+	return 0
+}
+
+func (g *Game) drawSettings(screen *ebiten.Image) {
+	drawBackdrop(screen, background)
+
+	title := tr("SETTINGS")
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, ui(100), color.White)
+
+	g.drawSettingRows(screen, settingsPanel, g.settingsIndex, ui(170))
+
+	help := tr("Up or Down to choose  Left or Right to change  R to reset  Esc to go back")
+	if settingsPanel[g.settingsIndex].open != nil {
+		help = tr("Up or Down to choose  %s to open  R to reset  Esc to go back", keyName(actionConfirm))
+	}
+	helpBounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-helpBounds.Dx())/2, windowHeight-ui(60), color.White)
+}
+
+// drawSettingRows lists settings from y down, with the selected one highlighted,
+// and returns the y of the next line.
+func (g *Game) drawSettingRows(screen *ebiten.Image, settings []setting, selected, y int) int {
+	lineHeight := text.BoundString(g.gameFont, "A").Dy() * 3 / 2
+	for i, s := range settings {
+		colour := color.Color(color.Gray{Y: 128})
+		if i == selected {
+			colour = color.White
+		}
+		text.Draw(screen, tr(s.name), g.gameFont, windowWidth/4, y, colour)
+		x := windowWidth/2 + ui(40)
+		if s.level != nil {
+			// A slider, with the value after it
+			width, height := float64(ui(100)), float64(lineHeight/2)
+			top := float64(y) - height
+			ebitenutil.DrawRect(screen, float64(x), top, width, height, color.Gray{Y: 64})
+			ebitenutil.DrawRect(screen, float64(x), top, width*s.level(), height, colour)
+			x += ui(110)
+		}
+		if s.value != nil {
+			text.Draw(screen, s.value(), g.gameFont, x, y, colour)
+		}
+		y += lineHeight
+	}
+	return y
+}
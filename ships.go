@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// spriteRect is a region of the sprite sheet written as [x0, y0, x1, y1] in the data files.
+type spriteRect [4]int
+
+func (r spriteRect) Rect() image.Rectangle {
+	return image.Rect(r[0], r[1], r[2], r[3])
+}
+
+// Projectile is a type of beam a ship can fire.
+type Projectile struct {
+	Sprite spriteRect `json:"sprite"`
+	Speed  float64    `json:"speed"`  // pixels per second
+	Pierce int        `json:"pierce"` // extra aliens the beam passes through before it stops
+}
+
+// Ship is one selectable laser cannon, loaded from files/ships.json.
+type Ship struct {
+	Name       string     `json:"name"`
+	Sprite     spriteRect `json:"sprite"`
+	Explode    spriteRect `json:"explode"`
+	Hitbox     spriteRect `json:"hitbox"` // relative to the top left of the sprite
+	Handling   Handling   `json:"handling"`
+	FireRate   float64    `json:"fireRate"` // shots per second
+	Projectile string     `json:"projectile"`
+	Lives      int        `json:"lives"`
+}
+
+var (
+	ships       []Ship
+	projectiles map[string]Projectile
+)
+
+func loadShips(path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var data struct {
+		Projectiles map[string]Projectile `json:"projectiles"`
+		Ships       []Ship                `json:"ships"`
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		log.Fatal("Error reading ", path, ": ", err)
+	}
+	if len(data.Ships) == 0 {
+		log.Fatal("No ships defined in ", path)
+	}
+	for _, ship := range data.Ships {
+		if _, ok := data.Projectiles[ship.Projectile]; !ok {
+			log.Fatalf("Ship %q uses unknown projectile %q", ship.Name, ship.Projectile)
+		}
+	}
+
+	ships = data.Ships
+	projectiles = data.Projectiles
+}
+
+// applyShip sets the ship every player flies, rebuilding any cannons already on the screen.
+func (g *Game) applyShip(ship Ship) {
+	g.ship = ship
+	g.handling = ship.Handling
+	for _, p := range g.players {
+		p.fitShip(ship)
+	}
+}
+
+// fitShip rebuilds the player's laser cannon and beam from a ship definition.
+func (p *Player) fitShip(ship Ship) {
+	p.cannon.size = sheetRect(ship.Sprite.Rect())
+	p.cannon.hitbox = sheetRect(ship.Hitbox.Rect())
+	p.cannon.Filter = src.SubImage(sheetRect(ship.Sprite.Rect())).(*ebiten.Image)
+	p.cannon.FilterE = src.SubImage(sheetRect(ship.Explode.Rect())).(*ebiten.Image)
+
+	projectile := projectiles[ship.Projectile]
+	p.beam.size = sheetRect(projectile.Sprite.Rect())
+	p.beam.Filter = src.SubImage(sheetRect(projectile.Sprite.Rect())).(*ebiten.Image)
+}
+
+// fireCooldownTicks is how many ticks the player has to wait between shots.
+func (g *Game) fireCooldownTicks(p *Player) int {
+	if g.ship.FireRate <= 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(ebiten.DefaultTPS) / (g.ship.FireRate * p.upgradeBonus().FireRate)))
+}
+
+// beamStep is how far the player's beam travels in one tick.
+func (g *Game) beamStep(p *Player) int {
+	speed := projectiles[g.ship.Projectile].Speed * p.upgradeBonus().BeamSpeed
+	return int(math.Round(speed * tickSeconds()))
+}
+
+// fire shoots the player's beam from the middle of their cannon, if it is ready.
+func (g *Game) fire(p *Player) {
+	if p.beam.Status || p.fireCooldown > 0 {
+		return
+	}
+	p.shotsFired++
+	p.fireCooldown = g.fireCooldownTicks(p)
+	if laserSound != nil && !g.silent() {
+		laserSound.Rewind()
+		laserSound.Play()
+	}
+	p.beam.Position.X = p.cannon.Position.X + (p.cannon.size.Dx()-p.beam.size.Dx())/2
+	p.beam.Status = true
+	p.beamPierce = projectiles[g.ship.Projectile].Pierce
+	p.beamHits = 0
+}
+
+func (g *Game) updateShipSelect() {
+	if justPressed(actionMoveRight) {
+		g.shipIndex = (g.shipIndex + 1) % len(ships)
+	}
+	if justPressed(actionMoveLeft) {
+		g.shipIndex = (g.shipIndex + len(ships) - 1) % len(ships)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.toggleScoring()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.mode = (g.mode + 1) % len(modeNames)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) && g.mode == modeCoop {
+		config.SharedLives = !config.SharedLives
+		saveConfig()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.pushScene(controlsScene{})
+		return
+	}
+	if justPressed(actionConfirm) || justPressed(actionFire) {
+		g.applyShip(ships[g.shipIndex])
+		g.startGame(g.mode)
+		g.startPlaying()
+	}
+}
+
+func (g *Game) drawShipSelect(screen *ebiten.Image) {
+	drawBackdrop(screen, background)
+
+	title := tr("SELECT YOUR SHIP")
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, ui(80), color.White)
+
+	// Lay the ships out in a row, each in its own column
+	columnWidth := windowWidth / len(ships)
+	for i, ship := range ships {
+		centreX := columnWidth*i + columnWidth/2
+		rect := sheetRect(ship.Sprite.Rect())
+		sprite := src.SubImage(rect).(*ebiten.Image)
+
+		// Draw the ship three times bigger so it is easy to see
+		const scale = 3
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(scale, scale)
+		op.GeoM.Translate(float64(centreX-rect.Dx()*scale/2), float64(ui(200)))
+		if i != g.shipIndex {
+			op.ColorScale.ScaleAlpha(0.4)
+		}
+		screen.DrawImage(sprite, op)
+
+		textColour := color.Color(color.Gray{Y: 128})
+		if i == g.shipIndex {
+			textColour = color.White
+		}
+		lines := []string{
+			ship.Name,
+			tr("Speed %d", int(ship.Handling.MaxSpeed)),
+			tr("Shots %d a min", int(ship.FireRate*60)),
+			tr("Beam %s", ship.Projectile),
+			tr("Lives %d", ship.Lives),
+		}
+		y := ui(300)
+		for _, line := range lines {
+			bounds := text.BoundString(g.gameFont, line)
+			text.Draw(screen, line, g.gameFont, centreX-bounds.Dx()/2, y, textColour)
+			y += bounds.Dy() + ui(12)
+		}
+	}
+
+	// The game font only has letters and digits, so no punctuation here
+	scoring := tr("Scoring %s  C to change", tr(g.scoring))
+	scoringBounds := text.BoundString(g.gameFont, scoring)
+	text.Draw(screen, scoring, g.gameFont, (windowWidth-scoringBounds.Dx())/2, windowHeight-ui(100), color.White)
+
+	players := tr("%s  P to change", tr(modeNames[g.mode]))
+	if g.mode == modeCoop {
+		players = tr("%s with separate lives  P or L to change", tr(modeNames[g.mode]))
+		if config.SharedLives {
+			players = tr("%s with shared lives  P or L to change", tr(modeNames[g.mode]))
+		}
+	}
+	playersBounds := text.BoundString(g.gameFont, players)
+	text.Draw(screen, players, g.gameFont, (windowWidth-playersBounds.Dx())/2, windowHeight-ui(140), color.White)
+
+	help := tr("%s or %s to choose  %s to play  K for keys", keyName(actionMoveLeft), keyName(actionMoveRight), keyName(actionConfirm))
+	helpBounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-helpBounds.Dx())/2, windowHeight-ui(60), color.White)
+}
+
+// findShip looks up a ship by name, including the ruleset's fixed ship.
+func findShip(name string) (Ship, bool) {
+	if ruleset.Ship != nil && ruleset.Ship.Name == name {
+		return *ruleset.Ship, true
+	}
+	for _, ship := range ships {
+		if ship.Name == name {
+			return ship, true
+		}
+	}
+	return Ship{}, false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"io/ioutil"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// pointsPerCredit is how many points of aliens it takes to earn one shop credit.
+const pointsPerCredit = 10
+
+// UpgradeEffect is what one level of an upgrade does.
+// Multipliers stack by multiplying, counts stack by adding.
+type UpgradeEffect struct {
+	BeamSpeed float64 `json:"beamSpeed"` // beam speed multiplier
+	FireRate  float64 `json:"fireRate"`  // shots per second multiplier
+	Barriers  int     `json:"barriers"`  // extra barriers built each wave
+	Armour    int     `json:"armour"`    // bomb hits absorbed each wave
+}
+
+// Upgrade is one item in the between-wave shop, loaded from files/upgrades.json.
+type Upgrade struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Price       int           `json:"price"`
+	MaxLevel    int           `json:"maxLevel"`
+	Effect      UpgradeEffect `json:"effect"`
+}
+
+// Purchase is one shop choice. A player's purchases are kept in the order they
+// were made, with the wave, so the rollback snapshots can put them back and a
+// replay can make them again after the same wave, see Run.
+type Purchase struct {
+	Wave    int    `json:"wave"`
+	Upgrade string `json:"upgrade"`
+}
+
+var upgrades []Upgrade
+
+func loadUpgrades(path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &upgrades); err != nil {
+		log.Fatal("Error reading ", path, ": ", err)
+	}
+	if len(upgrades) == 0 {
+		log.Fatal("No upgrades defined in ", path)
+	}
+}
+
+func findUpgrade(id string) (Upgrade, bool) {
+	for _, upgrade := range upgrades {
+		if upgrade.ID == id {
+			return upgrade, true
+		}
+	}
+	return Upgrade{}, false
+}
+
+// upgradeLevel is how many times the upgrade has been bought this run.
+func (p *Player) upgradeLevel(id string) int {
+	level := 0
+	for _, purchase := range p.purchases {
+		if purchase.Upgrade == id {
+			level++
+		}
+	}
+	return level
+}
+
+// upgradeBonus adds up every upgrade bought this run.
+func (p *Player) upgradeBonus() UpgradeEffect {
+	bonus := UpgradeEffect{BeamSpeed: 1, FireRate: 1}
+	for _, purchase := range p.purchases {
+		upgrade, ok := findUpgrade(purchase.Upgrade)
+		if !ok {
+			continue
+		}
+		if upgrade.Effect.BeamSpeed > 0 {
+			bonus.BeamSpeed *= upgrade.Effect.BeamSpeed
+		}
+		if upgrade.Effect.FireRate > 0 {
+			bonus.FireRate *= upgrade.Effect.FireRate
+		}
+		bonus.Barriers += upgrade.Effect.Barriers
+		bonus.Armour += upgrade.Effect.Armour
+	}
+	return bonus
+}
+
+// buyUpgrade spends credits on an upgrade, returning false if it can't be bought.
+func (p *Player) buyUpgrade(upgrade Upgrade) bool {
+	if p.credits < upgrade.Price || p.upgradeLevel(upgrade.ID) >= upgrade.MaxLevel {
+		return false
+	}
+	p.credits -= upgrade.Price
+	p.purchases = append(p.purchases, Purchase{Wave: p.board.wave, Upgrade: upgrade.ID})
+	return true
+}
+
+// leaveShop starts the next wave, once everyone has shopped.
+func (g *Game) leaveShop() {
+	if g.mode == modeCoop {
+		g.current = 0
+	}
+	g.board().wave++
+	g.startWave(g.board())
+}
+
+func (g *Game) updateShop() {
+	if menuDown() {
+		g.shopIndex = (g.shopIndex + 1) % len(upgrades)
+	}
+	if menuUp() {
+		g.shopIndex = (g.shopIndex + len(upgrades) - 1) % len(upgrades)
+	}
+	if justPressed(actionConfirm) {
+		g.player().buyUpgrade(upgrades[g.shopIndex])
+	}
+	if justPressed(actionFire) {
+		if g.nextShopper() {
+			g.shopIndex = 0 // Each co-op player shops in turn
+			return
+		}
+		g.popScene() // On to the next wave, see waveClearScene
+	}
+}
+
+func (g *Game) drawShop(screen *ebiten.Image) {
+	drawBackdrop(screen, background)
+
+	p := g.player()
+	title := tr("WAVE %d CLEARED", p.board.wave)
+	titleBounds := text.BoundString(g.gameOverFont, title)
+	text.Draw(screen, title, g.gameOverFont, (windowWidth-titleBounds.Dx())/2, 80, color.White)
+
+	credits := tr("Credits %d", p.credits)
+	if g.mode == modeCoop {
+		credits = p.name + "  " + credits
+	}
+	creditsBounds := text.BoundString(g.gameFont, credits)
+	text.Draw(screen, credits, g.gameFont, (windowWidth-creditsBounds.Dx())/2, 130, paletteColour(p.colour, 130))
+
+	y := 200
+	for i, upgrade := range upgrades {
+		level := p.upgradeLevel(upgrade.ID)
+		line := tr("%s  %d of %d", upgrade.Name, level, upgrade.MaxLevel) + "  " + tr("%d credits", upgrade.Price)
+		if level >= upgrade.MaxLevel {
+			line = tr("%s  %d of %d", upgrade.Name, level, upgrade.MaxLevel) + "  " + tr("SOLD OUT")
+		}
+
+		textColour := color.Color(color.Gray{Y: 128})
+		if i == g.shopIndex {
+			textColour = color.White
+		}
+		text.Draw(screen, line, g.gameFont, 120, y, textColour)
+		if i == g.shopIndex {
+			text.Draw(screen, upgrade.Description, g.gameFont, 150, y+28, color.Gray{Y: 200})
+			y += 28
+		}
+		y += 44
+	}
+
+	help := tr("Up or Down to choose  %s to buy  %s for next wave", keyName(actionConfirm), keyName(actionFire))
+	helpBounds := text.BoundString(g.gameFont, help)
+	text.Draw(screen, help, g.gameFont, (windowWidth-helpBounds.Dx())/2, windowHeight-60, color.White)
+}
@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestUpgradeLevelAndBonus(t *testing.T) {
+	saved := upgrades
+	t.Cleanup(func() { upgrades = saved })
+	upgrades = []Upgrade{
+		{ID: "beam", Price: 10, MaxLevel: 3, Effect: UpgradeEffect{BeamSpeed: 1.5}},
+		{ID: "fire", Price: 10, MaxLevel: 3, Effect: UpgradeEffect{FireRate: 2}},
+		{ID: "barrier", Price: 10, MaxLevel: 2, Effect: UpgradeEffect{Barriers: 1}},
+		{ID: "armour", Price: 10, MaxLevel: 2, Effect: UpgradeEffect{Armour: 1}},
+	}
+	tests := []struct {
+		name      string
+		purchases []string
+		beam      int
+		want      UpgradeEffect
+	}{
+		{"nothing", nil, 0, UpgradeEffect{BeamSpeed: 1, FireRate: 1}},
+		{"one beam", []string{"beam"}, 1, UpgradeEffect{BeamSpeed: 1.5, FireRate: 1}},
+		{"multipliers multiply", []string{"beam", "beam", "fire"}, 2, UpgradeEffect{BeamSpeed: 2.25, FireRate: 2}},
+		{"counts add", []string{"barrier", "armour", "barrier"}, 0, UpgradeEffect{BeamSpeed: 1, FireRate: 1, Barriers: 2, Armour: 1}},
+		{"unknown upgrade", []string{"gone", "beam"}, 1, UpgradeEffect{BeamSpeed: 1.5, FireRate: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Player{}
+			for _, id := range test.purchases {
+				p.purchases = append(p.purchases, Purchase{Wave: 1, Upgrade: id})
+			}
+			if level := p.upgradeLevel("beam"); level != test.beam {
+				t.Errorf("upgradeLevel(beam) = %d, want %d", level, test.beam)
+			}
+			if bonus := p.upgradeBonus(); bonus != test.want {
+				t.Errorf("upgradeBonus() = %+v, want %+v", bonus, test.want)
+			}
+		})
+	}
+}
+
+func TestBuyUpgrade(t *testing.T) {
+	upgrade := Upgrade{ID: "barrier", Price: 30, MaxLevel: 2}
+	tests := []struct {
+		name        string
+		credits     int
+		bought      int
+		want        bool
+		wantCredits int
+	}{
+		{"can afford", 40, 0, true, 10},
+		{"exactly enough", 30, 1, true, 0},
+		{"too poor", 29, 0, false, 29},
+		{"sold out", 100, 2, false, 100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Player{credits: test.credits, board: &Board{wave: 3}}
+			for range test.bought {
+				p.purchases = append(p.purchases, Purchase{Wave: 1, Upgrade: upgrade.ID})
+			}
+			if got := p.buyUpgrade(upgrade); got != test.want {
+				t.Fatalf("buyUpgrade() = %v, want %v", got, test.want)
+			}
+			if p.credits != test.wantCredits {
+				t.Errorf("credits = %d, want %d", p.credits, test.wantCredits)
+			}
+			if test.want {
+				last := p.purchases[len(p.purchases)-1]
+				if last != (Purchase{Wave: 3, Upgrade: upgrade.ID}) {
+					t.Errorf("last purchase = %+v, want wave 3 %s", last, upgrade.ID)
+				}
+			}
+		})
+	}
+}
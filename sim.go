@@ -0,0 +1,215 @@
+package main
+
+import (
+	"image"
+	"math/rand/v2"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+// explosionTicks is how long an explosion stays on the screen.
+const explosionTicks = 6
+
+// explosion is an alien or cannon that has just been hit.
+type explosion struct {
+	image    *ebiten.Image
+	position image.Point
+	ticks    int
+}
+
+// seedRandom seeds the game's random numbers. Everything random in the simulation
+// comes from g.rng, so two machines with the same seed and inputs play the same game.
+func (g *Game) seedRandom(seed uint64) {
+	g.pcg = rand.NewPCG(seed, seed)
+	g.rng = rand.New(g.pcg)
+}
+
+// step advances the game one tick. It doesn't draw anything, so the game plays
+// the same whatever the frame rate.
+func (g *Game) step() {
+	b := g.board()
+	players := g.activePlayers()
+	if g.mode == modeVersus {
+		g.updateInvader() // Player 2 marches and fires the formation
+	} else {
+		g.moveFormation(b)
+	}
+	if ruleset.ColumnFiringTable != nil && g.mode != modeVersus {
+		g.fireColumnBomb(b, players)
+	}
+	g.updateUFO()
+	g.simulate(b, players)
+	g.updatePopups()
+	g.loop++
+	if g.assistActive() {
+		g.assisted = true
+	}
+
+	if g.gameOver || g.prompt != promptNone || !b.cleared() {
+		return
+	}
+	if g.mode == modeVersus {
+		g.endRound(versusDefender) // The defender wins the round by clearing the formation
+		return
+	}
+	for _, p := range g.players {
+		p.resetBeam()
+	}
+	if ruleset.Shop && g.net == nil && !g.demo {
+		// Online co-op skips the shop, there's no waiting for each other to finish buying
+		if g.replay != nil {
+			g.replay.shopping = true // Buys what the run bought, see replayTick
+		} else {
+			g.pushScene(waveClearScene{})
+		}
+	} else {
+		b.wave++
+		g.startWave(b)
+	}
+}
+
+// moveFormation marches the board's aliens, on the march table under classic rules
+// and a little every tick under relaxed rules.
+func (g *Game) moveFormation(b *Board) {
+	if ruleset.MarchTable != nil {
+		g.march(b)
+		return
+	}
+	aliens := b.aliens
+	if aliens[0].Position.X < alienSize || aliens[aliensPerRow-1].Position.X > windowWidth-(2*alienSize) {
+		b.alienDirection = b.alienDirection * -1
+		for i := 0; i < len(aliens); i++ {
+			aliens[i].Position.Y = aliens[i].Position.Y + ruleset.MarchDrop
+		}
+	}
+	for i := 0; i < len(aliens); i++ {
+		aliens[i].Position.X = aliens[i].Position.X + ruleset.MarchStep*b.alienDirection
+	}
+}
+
+// simulate moves the bombs and beams on a board and works out what they hit.
+// players are the cannons on the board.
+func (g *Game) simulate(b *Board, players []*Player) {
+	aliens := b.aliens
+	for i := 0; i < len(aliens); i++ {
+		if !aliens[i].Status {
+			continue
+		}
+		for _, shooter := range players {
+			if !shooter.beam.Status || !collide(aliens[i], shooter.beam) {
+				continue
+			}
+			aliens[i].Status = false
+			b.explode(aliens[i].FilterE, aliens[i].Position)
+			g.awardKill(shooter, aliens[i])
+			g.checkExtraLife(shooter)
+			g.play(b, explosionSound)
+			if shooter.beamPierce > 0 {
+				shooter.beamPierce--
+			} else {
+				shooter.resetBeam()
+			}
+			break
+		}
+
+		if aliens[i].Status && ruleset.ColumnFiringTable == nil && g.mode != modeVersus && g.rng.Float64() < bombProbability*g.difficulty() {
+			b.dropBomb(aliens[i])
+		}
+	}
+
+	for i := 0; i < len(b.bombs); i++ {
+		if !b.bombs[i].Status {
+			continue
+		}
+		b.bombs[i].Position.Y = b.bombs[i].Position.Y + g.bombStep()
+		// Every cannon on the screen can be hit, the first one the bomb touches takes it
+		for _, target := range players {
+			if target.lives <= 0 || !collide(b.bombs[i], target.cannon) {
+				continue
+			}
+			b.bombs[i].Status = false
+			if target.armour > 0 {
+				target.armour-- // Armour soaks up the hit
+				break
+			}
+			b.explode(target.cannon.FilterE, target.cannon.Position)
+			g.play(b, shipExplosionSound)
+			if g.noDeath() {
+				g.breakCombo(target) // The hit still costs the combo, just not a life
+				break
+			}
+			g.loseLife(target)
+			g.breakCombo(target)
+			if target.lives <= 0 {
+				g.playerOut(target)
+			} else if g.mode == modeAlternating {
+				g.nextPlayer() // Players take turns after each death
+			} else {
+				target.resetBeam()
+				target.cannon.Position.Y = playerYPosition
+			}
+			break
+		}
+	}
+	if g.gameOver || g.prompt != promptNone {
+		return // The board has been reset for the next turn or round
+	}
+
+	// Beams only ever hit aliens and the UFO, never the other cannon
+	for _, player := range players {
+		if player.beam.Status {
+			player.beam.Position.Y -= g.beamStep(player)
+		}
+		if player.beam.Position.Y < 0 {
+			if player.beamHits == 0 {
+				g.breakCombo(player) // Missed shot
+			}
+			player.resetBeam()
+		}
+	}
+
+	for i := range aliens {
+		if aliens[i].Status && aliens[i].Position.Y > playerYPosition-ruleset.InvasionMargin && !g.gameOver && len(players) > 0 {
+			if g.noDeath() {
+				g.startWave(b) // The formation starts the wave again from the top
+				return
+			}
+			// The invasion ends the game for everyone on this board, whatever lives they had left
+			for _, player := range players {
+				player.lives = 0
+			}
+			g.playerOut(players[0])
+			break
+		}
+	}
+
+	explosions := b.explosions[:0]
+	for _, e := range b.explosions {
+		e.ticks--
+		if e.ticks > 0 {
+			explosions = append(explosions, e)
+		}
+	}
+	b.explosions = explosions
+}
+
+// explode shows an explosion on the board for a few ticks.
+func (b *Board) explode(img *ebiten.Image, position image.Point) {
+	b.explosions = append(b.explosions, explosion{image: img, position: position, ticks: explosionTicks})
+}
+
+// silent is whether the game keeps its sounds to itself: while replaying frames
+// after a rollback, since they were heard the first time, and in the demo.
+func (g *Game) silent() bool {
+	return g.resimulating || g.demo || g.replay != nil
+}
+
+// play starts a sound effect for something that happened on the board being shown.
+func (g *Game) play(b *Board, sound *audio.Player) {
+	if sound == nil || b != g.board() || g.silent() {
+		return
+	}
+	sound.Rewind()
+	sound.Play()
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// A game started with -spectate-server streams what is on its screen over WebSocket,
+// and games started with -spectate show it. Every message is the whole world, so
+// someone who tunes in halfway through a match sees it straight away.
+
+// spectateInterval is how often a frame goes to the spectators, by the clock, so
+// the stream keeps its rate when the game speed setting slows the game down.
+const spectateInterval = 50 * time.Millisecond // 20 frames a second
+
+// spectatorFrame is everything a spectator needs to draw the game, sent as JSON.
+type spectatorFrame struct {
+	Rules         string            `json:"rules"`
+	Ship          string            `json:"ship"`
+	Scoring       string            `json:"scoring"`
+	Mode          int               `json:"mode"`
+	Status        string            `json:"status,omitempty"` // Shown across the screen, e.g. while the players shop
+	Loop          int               `json:"loop"`
+	GameOver      bool              `json:"gameOver,omitempty"`
+	Current       int               `json:"current"`
+	Players       []spectatorPlayer `json:"players"`
+	Boards        []spectatorBoard  `json:"boards"`
+	UFO           spot              `json:"ufo"`
+	UFOScore      int               `json:"ufoScore,omitempty"`
+	UFOScoreX     int               `json:"ufoScoreX,omitempty"`
+	UFOScoreTicks int               `json:"ufoScoreTicks,omitempty"`
+	Versus        *spectatorVersus  `json:"versus,omitempty"`
+}
+
+// spot is where a sprite is and whether it is showing.
+type spot struct {
+	X  int  `json:"x"`
+	Y  int  `json:"y"`
+	On bool `json:"on,omitempty"`
+}
+
+func spotOf(s Sprite) spot {
+	return spot{X: s.Position.X, Y: s.Position.Y, On: s.Status}
+}
+
+func (s spot) point() image.Point {
+	return image.Pt(s.X, s.Y)
+}
+
+type spectatorPlayer struct {
+	Name    string     `json:"name"`
+	Score   int        `json:"score"`
+	Lives   int        `json:"lives"`
+	Credits int        `json:"credits"`
+	Armour  int        `json:"armour"`
+	Combo   int        `json:"combo"`
+	Colour  color.RGBA `json:"colour"`
+	Cannon  spot       `json:"cannon"`
+	Beam    spot       `json:"beam"`
+	Board   int        `json:"board"` // Index into the frame's boards
+}
+
+type spectatorBoard struct {
+	Wave       int                  `json:"wave"`
+	Incoming   int                  `json:"incoming,omitempty"`
+	Aliens     []spot               `json:"aliens"`
+	Barriers   []spot               `json:"barriers"`
+	Bombs      []spot               `json:"bombs,omitempty"`
+	Attackers  []spot               `json:"attackers,omitempty"`
+	Explosions []spectatorExplosion `json:"explosions,omitempty"`
+}
+
+type spectatorExplosion struct {
+	spot
+	Cannon int `json:"cannon"` // Player whose cannon blew up, or -1 for an alien
+}
+
+type spectatorVersus struct {
+	Round        int    `json:"round"`
+	Wins         [2]int `json:"wins"`
+	Column       int    `json:"column"`
+	Bombs        int    `json:"bombs"`
+	FireCooldown int    `json:"fireCooldown"`
+	UFOCooldown  int    `json:"ufoCooldown"`
+}
+
+// spectatorFrame captures the world for the spectators.
+func (g *Game) spectatorFrame() spectatorFrame {
+	f := spectatorFrame{
+		Rules:         ruleset.Name,
+		Ship:          g.ship.Name,
+		Scoring:       g.scoring,
+		Mode:          g.mode,
+		Status:        g.spectatorStatus(),
+		Loop:          g.loop,
+		GameOver:      g.gameOver,
+		Current:       max(g.current, 0), // Nobody is current while co-op players take turns in the shop
+		UFO:           spotOf(ufo),
+		UFOScore:      g.ufoScore,
+		UFOScoreX:     g.ufoScoreX,
+		UFOScoreTicks: g.ufoScoreTicks,
+	}
+	if g.mode == modeVersus {
+		v := g.versus
+		f.Versus = &spectatorVersus{Round: v.round, Wins: v.wins, Column: v.column, Bombs: v.bombs, FireCooldown: v.fireCooldown, UFOCooldown: v.ufoCooldown}
+	}
+	index := map[*Board]int{}
+	for _, p := range g.players {
+		i, ok := index[p.board]
+		if !ok {
+			i = len(f.Boards)
+			index[p.board] = i
+			f.Boards = append(f.Boards, g.spectatorBoard(p.board))
+		}
+		f.Players = append(f.Players, spectatorPlayer{
+			Name:    p.name,
+			Score:   p.score,
+			Lives:   p.lives,
+			Credits: p.credits,
+			Armour:  p.armour,
+			Combo:   p.comboStreak,
+			Colour:  p.colour,
+			Cannon:  spotOf(p.cannon),
+			Beam:    spotOf(p.beam),
+			Board:   i,
+		})
+	}
+	return f
+}
+
+func (g *Game) spectatorBoard(b *Board) spectatorBoard {
+	f := spectatorBoard{Wave: b.wave, Incoming: b.pendingAttackers}
+	for _, alien := range b.aliens {
+		f.Aliens = append(f.Aliens, spotOf(alien))
+	}
+	for _, barrier := range b.barriers {
+		f.Barriers = append(f.Barriers, spotOf(barrier))
+	}
+	for _, bomb := range b.bombs {
+		if bomb.Status {
+			f.Bombs = append(f.Bombs, spotOf(bomb))
+		}
+	}
+	for _, attacker := range b.attackers {
+		f.Attackers = append(f.Attackers, spotOf(attacker))
+	}
+	for _, e := range b.explosions {
+		cannon := -1
+		for i, p := range g.players {
+			if e.image == p.cannon.FilterE {
+				cannon = i
+			}
+		}
+		f.Explosions = append(f.Explosions, spectatorExplosion{spot: spot{X: e.position.X, Y: e.position.Y, On: true}, Cannon: cannon})
+	}
+	return f
+}
+
+// spectatorStatus says what the players are doing when it isn't playing, in English
+// so that each spectator can show it in their own language.
+func (g *Game) spectatorStatus() string {
+	switch g.scene().(type) {
+	case titleScene:
+		return "ON THE TITLE SCREEN"
+	case demoScene:
+		return "DEMO"
+	case modeSelectScene:
+		return "CHOOSING A SHIP"
+	case nameEntryScene:
+		return "GETTING READY"
+	case highScoreEntryScene:
+		return "NEW HIGH SCORE"
+	case waveClearScene:
+		return "SHOPPING"
+	case pausedScene, settingsScene, controlsScene:
+		return "PAUSED"
+	case playingScene:
+		if g.prompt != promptNone {
+			return "GETTING READY"
+		}
+	}
+	return ""
+}
+
+// spectateServer sends the game to every spectator connected to it.
+type spectateServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[chan []byte]bool
+	latest   []byte // Sent first to spectators who join late
+}
+
+// startSpectateServer listens for spectators on addr, e.g. ":8080".
+func startSpectateServer(addr string) (*spectateServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &spectateServer{listener: listener, clients: map[chan []byte]bool{}}
+	go http.Serve(listener, s)
+	go s.stream()
+	return s, nil
+}
+
+// stream sends the newest frame to every spectator every spectateInterval, the
+// same one again if the game hasn't made a new one since.
+func (s *spectateServer) stream() {
+	for range time.Tick(spectateInterval) {
+		s.mu.Lock()
+		for frames := range s.clients {
+			select {
+			case <-frames: // Replaced, if they haven't been sent the last one yet
+			default:
+			}
+			if s.latest != nil {
+				frames <- s.latest
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ServeHTTP streams frames to one spectator until they go.
+func (s *spectateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := wsUpgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Only the newest frame is worth sending, so a slow spectator skips frames rather than falling behind
+	frames := make(chan []byte, 1)
+	s.mu.Lock()
+	s.clients[frames] = true
+	if s.latest != nil {
+		frames <- s.latest
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, frames)
+		s.mu.Unlock()
+	}()
+
+	// Spectators don't send anything but closes and pongs
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, opcode, _, err := wsReadFrame(rw.Reader)
+			if err != nil || opcode == wsClose {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data := <-frames:
+			if err := wsWriteFrame(conn, wsText, data, false); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// broadcast makes data the frame stream sends to every spectator.
+func (s *spectateServer) broadcast(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = data
+}
+
+// updateSpectators captures the world for the spectators, as often as the stream sends it.
+func (g *Game) updateSpectators() {
+	if time.Since(g.spectateSent) < spectateInterval {
+		return
+	}
+	g.spectateSent = time.Now()
+	data, err := json.Marshal(g.spectatorFrame())
+	if err != nil {
+		log.Println("Error sending to spectators:", err)
+		return
+	}
+	g.spectators.broadcast(data)
+}
+
+// spectatorClient is a game that only watches another one.
+type spectatorClient struct {
+	conn   net.Conn
+	mu     sync.Mutex
+	latest *spectatorFrame
+	ended  bool
+
+	boards         []*Board
+	bomb           Sprite // Copied for each bomb shown, so the sprite sheet isn't cut up every frame
+	attacker       Sprite
+	alienExplosion *ebiten.Image
+}
+
+// watchGame connects to a spectator stream at a ws:// address and waits for the first frame,
+// so the game can be set up with the right rules before it starts.
+func watchGame(address string) (*spectatorClient, spectatorFrame, error) {
+	conn, r, err := wsDial(address)
+	if err != nil {
+		return nil, spectatorFrame{}, err
+	}
+	var first spectatorFrame
+	data, err := wsReadMessage(r, conn, true)
+	if err == nil {
+		err = json.Unmarshal(data, &first)
+	}
+	if err == nil {
+		err = first.check(rulesetByName(first.Rules)) // The rules main will play it by
+	}
+	if err != nil {
+		conn.Close()
+		return nil, spectatorFrame{}, err
+	}
+	c := &spectatorClient{conn: conn}
+	go c.listen(r)
+	return c, first, nil
+}
+
+// listen keeps the newest frame from the stream until the game takes it.
+func (c *spectatorClient) listen(r *bufio.Reader) {
+	for {
+		data, err := wsReadMessage(r, c.conn, true)
+		if err != nil {
+			c.mu.Lock()
+			c.ended = true
+			c.mu.Unlock()
+			return
+		}
+		var f spectatorFrame
+		if err := json.Unmarshal(data, &f); err != nil || f.check(ruleset) != nil {
+			continue
+		}
+		c.mu.Lock()
+		c.latest = &f
+		c.mu.Unlock()
+	}
+}
+
+// take hands over the newest frame, or nil if nothing new has arrived.
+func (c *spectatorClient) take() (*spectatorFrame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := c.latest
+	c.latest = nil
+	return f, c.ended
+}
+
+// leave closes the stream.
+func (c *spectatorClient) leave() {
+	wsWriteFrame(c.conn, wsClose, nil, true)
+	c.conn.Close()
+}
+
+// updateSpectating shows the newest frame from the game being watched. Esc is the only key.
+func (g *Game) updateSpectating() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.quit()
+	}
+	f, ended := g.spectator.take()
+	if f != nil {
+		g.showFrame(*f)
+	}
+	g.spectator.ended = ended
+}
+
+// check is an error if the frame couldn't have come from a game played by rules,
+// so showFrame and drawing it can't be sent off the end of their lists.
+func (f spectatorFrame) check(rules Ruleset) error {
+	switch {
+	case f.Mode < modeSolo || f.Mode > modeLAN:
+		return errors.New("spectator frame has an unknown mode")
+	case len(f.Players) == 0 || len(f.Players) > len(playerColours):
+		return errors.New("spectator frame has the wrong number of players")
+	case (f.Mode == modeCoop || f.Mode == modeVersus || f.Mode == modeLAN) && len(f.Players) != 2:
+		return errors.New("spectator frame has the wrong number of players for its mode")
+	case f.Current < 0 || f.Current >= len(f.Players):
+		return errors.New("spectator frame's current player isn't one of its players")
+	case len(f.Boards) > len(f.Players):
+		return errors.New("spectator frame has more boards than players")
+	case f.Versus != nil && f.Mode != modeVersus:
+		return errors.New("spectator frame has versus rounds outside versus")
+	case f.Versus != nil && (f.Versus.Column < 0 || f.Versus.Column >= rules.Columns):
+		return errors.New("spectator frame's invader is on a column the formation hasn't got")
+	}
+	for _, p := range f.Players {
+		if p.Board < 0 || p.Board >= len(f.Boards) {
+			return errors.New("spectator frame has a player on a board it hasn't got")
+		}
+	}
+	return nil
+}
+
+// showFrame sets the world up to look like the frame, which has passed check.
+func (g *Game) showFrame(f spectatorFrame) {
+	c := g.spectator
+	if f.Ship != g.ship.Name && ruleset.Ship == nil {
+		if ship, ok := findShip(f.Ship); ok {
+			g.applyShip(ship)
+		}
+	}
+	if f.Mode != g.mode || len(f.Players) != len(g.players) {
+		g.mode = f.Mode
+		g.players = nil
+		for i := range f.Players {
+			g.players = append(g.players, g.newPlayer("", i))
+		}
+	}
+	for len(c.boards) < len(f.Boards) {
+		b := &Board{}
+		b.buildFormation()
+		c.boards = append(c.boards, b)
+	}
+	if c.alienExplosion == nil {
+		c.bomb = newBomb(image.Point{})
+		c.attacker = createAlien(0, 0, alien1Sprite, alien1aSprite, lanAttackerPoints)
+		c.alienExplosion = src.SubImage(sheetRect(alienExplode)).(*ebiten.Image)
+	}
+
+	g.scoring = f.Scoring
+	g.loop = f.Loop
+	g.gameOver = f.GameOver
+	g.current = min(f.Current, len(g.players)-1)
+	for i, fp := range f.Players {
+		p := g.players[i]
+		p.name = fp.Name
+		p.score = fp.Score
+		p.lives = fp.Lives
+		p.credits = fp.Credits
+		p.armour = fp.Armour
+		p.comboStreak = fp.Combo
+		p.colour = fp.Colour
+		p.cannon.Position = fp.Cannon.point()
+		p.cannon.Status = fp.Cannon.On
+		p.beam.Position = fp.Beam.point()
+		p.beam.Status = fp.Beam.On
+		p.board = c.boards[fp.Board]
+	}
+	for i, fb := range f.Boards {
+		c.showBoard(c.boards[i], fb, g.players)
+	}
+
+	ufo.Position = f.UFO.point()
+	ufo.Status = f.UFO.On
+	g.ufoScore = f.UFOScore
+	g.ufoScoreX = f.UFOScoreX
+	g.ufoScoreTicks = f.UFOScoreTicks
+	if f.Versus != nil {
+		g.versus.round = f.Versus.Round
+		g.versus.wins = f.Versus.Wins
+		g.versus.column = f.Versus.Column
+		g.versus.bombs = f.Versus.Bombs
+		g.versus.fireCooldown = f.Versus.FireCooldown
+		g.versus.ufoCooldown = f.Versus.UFOCooldown
+	}
+	g.spectatorStatusText = f.Status
+}
+
+func (c *spectatorClient) showBoard(b *Board, f spectatorBoard, players []*Player) {
+	b.wave = f.Wave
+	b.pendingAttackers = f.Incoming
+	for i := range min(len(b.aliens), len(f.Aliens)) {
+		b.aliens[i].Position = f.Aliens[i].point()
+		b.aliens[i].Status = f.Aliens[i].On
+	}
+	if len(b.barriers) != len(f.Barriers) {
+		b.buildBarriers(len(f.Barriers))
+	}
+	for i, s := range f.Barriers {
+		b.barriers[i].Position = s.point()
+		b.barriers[i].Status = s.On
+	}
+	b.bombs = b.bombs[:0]
+	for _, s := range f.Bombs {
+		bomb := c.bomb
+		bomb.Position = s.point()
+		b.bombs = append(b.bombs, bomb)
+	}
+	b.attackers = b.attackers[:0]
+	for _, s := range f.Attackers {
+		attacker := c.attacker
+		attacker.Position = s.point()
+		b.attackers = append(b.attackers, attacker)
+	}
+	b.explosions = b.explosions[:0]
+	for _, e := range f.Explosions {
+		img := c.alienExplosion
+		if e.Cannon >= 0 && e.Cannon < len(players) {
+			img = players[e.Cannon].cannon.FilterE
+		}
+		b.explosions = append(b.explosions, explosion{image: img, position: e.point(), ticks: explosionTicks})
+	}
+}
+
+// drawSpectating draws the game being watched, with what the players are doing across the middle.
+func (g *Game) drawSpectating(screen *ebiten.Image) {
+	g.drawGameScreen(screen)
+
+	// Over the middle of the screen, since the HUD has the corners
+	label := tr("SPECTATING")
+	bounds := text.BoundString(g.gameFont, label)
+	text.Draw(screen, label, g.gameFont, (windowWidth-bounds.Dx())/2, windowHeight/2-bounds.Dy()*3, color.RGBA{0xff, 0x20, 0x20, 0xff})
+
+	var lines []string
+	switch {
+	case g.spectator.ended:
+		lines = []string{tr("STREAM ENDED"), tr("Press Esc to close the game")}
+	case g.gameOver:
+		lines = []string{tr("GAME OVER"), g.playerScores()}
+	case g.spectatorStatusText != "":
+		lines = []string{tr(g.spectatorStatusText)}
+	}
+	y := windowHeight / 2
+	for _, line := range lines {
+		bounds := text.BoundString(g.gameFont, line)
+		text.Draw(screen, line, g.gameFont, (windowWidth-bounds.Dx())/2, y, color.White)
+		y += bounds.Dy() * 2
+	}
+}
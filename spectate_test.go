@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestSpectatorFrameCheck(t *testing.T) {
+	valid := func() spectatorFrame {
+		return spectatorFrame{
+			Mode:    modeCoop,
+			Current: 1,
+			Players: []spectatorPlayer{{Board: 0}, {Board: 0}},
+			Boards:  []spectatorBoard{{Wave: 1}},
+		}
+	}
+	tests := []struct {
+		name  string
+		spoil func(f *spectatorFrame)
+		ok    bool
+	}{
+		{"valid", func(f *spectatorFrame) {}, true},
+		{"alternating", func(f *spectatorFrame) {
+			f.Mode = modeAlternating
+			f.Boards = append(f.Boards, spectatorBoard{})
+			f.Players[1].Board = 1
+		}, true},
+		{"versus", func(f *spectatorFrame) { f.Mode, f.Versus = modeVersus, &spectatorVersus{} }, true},
+		{"unknown mode", func(f *spectatorFrame) { f.Mode = 99 }, false},
+		{"negative mode", func(f *spectatorFrame) { f.Mode = -1 }, false},
+		{"no players", func(f *spectatorFrame) { f.Players, f.Current = nil, 0 }, false},
+		{"too many players", func(f *spectatorFrame) { f.Players = append(f.Players, spectatorPlayer{}) }, false},
+		{"current too big", func(f *spectatorFrame) { f.Current = 2 }, false},
+		{"current negative", func(f *spectatorFrame) { f.Current = -1 }, false},
+		{"board missing", func(f *spectatorFrame) { f.Players[1].Board = 1 }, false},
+		{"board negative", func(f *spectatorFrame) { f.Players[0].Board = -1 }, false},
+		{"no boards", func(f *spectatorFrame) { f.Boards = nil }, false},
+		{"too many boards", func(f *spectatorFrame) { f.Boards = make([]spectatorBoard, 3) }, false},
+		{"versus outside versus", func(f *spectatorFrame) { f.Versus = &spectatorVersus{} }, false},
+		{"last versus column", func(f *spectatorFrame) {
+			f.Mode, f.Versus = modeVersus, &spectatorVersus{Column: relaxedRules().Columns - 1}
+		}, true},
+		{"versus column negative", func(f *spectatorFrame) { f.Mode, f.Versus = modeVersus, &spectatorVersus{Column: -1} }, false},
+		{"versus column past the formation", func(f *spectatorFrame) {
+			f.Mode, f.Versus = modeVersus, &spectatorVersus{Column: relaxedRules().Columns}
+		}, false},
+		{"solo", func(f *spectatorFrame) { f.Mode, f.Players, f.Current = modeSolo, f.Players[:1], 0 }, true},
+		{"one player co op", func(f *spectatorFrame) { f.Players, f.Current = f.Players[:1], 0 }, false},
+		{"one player versus", func(f *spectatorFrame) {
+			f.Mode, f.Versus, f.Players, f.Current = modeVersus, &spectatorVersus{}, f.Players[:1], 0
+		}, false},
+		{"one player LAN", func(f *spectatorFrame) { f.Mode, f.Players, f.Current = modeLAN, f.Players[:1], 0 }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := valid()
+			test.spoil(&f)
+			if err := f.check(relaxedRules()); (err == nil) != test.ok {
+				t.Errorf("check(relaxed) = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
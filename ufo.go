@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// The sprite sheet has no UFO, so it is drawn from this pattern instead.
+var ufoPattern = []string{
+	".....######.....",
+	"...##########...",
+	"..############..",
+	".##.##.##.##.##.",
+	"################",
+	"..###..##..###..",
+	"...#........#...",
+}
+
+var ufo Sprite
+
+var ufoScoreColour = color.RGBA{0xff, 0x20, 0x20, 0xff}
+
+// newUFOImage draws ufoPattern into an image.
+func newUFOImage() *ebiten.Image {
+	img := ebiten.NewImage(len(ufoPattern[0]), len(ufoPattern))
+	pixels := make([]byte, 4*len(ufoPattern[0])*len(ufoPattern))
+	for y, row := range ufoPattern {
+		for x, c := range row {
+			if c != '#' {
+				continue
+			}
+			i := 4 * (y*len(row) + x)
+			pixels[i], pixels[i+1], pixels[i+2], pixels[i+3] = 0xff, 0x20, 0x20, 0xff
+		}
+	}
+	img.WritePixels(pixels)
+	return img
+}
+
+func createUFO() Sprite {
+	width, height := len(ufoPattern[0]), len(ufoPattern)
+	return Sprite{
+		size:    image.Rect(0, 0, width, height),
+		Filter:  newUFOImage(),
+		FilterE: src.SubImage(sheetRect(alienExplode)).(*ebiten.Image),
+	}
+}
+
+// updateUFO launches the UFO on the ruleset's timer and flies it across the top of the screen.
+// In versus the invader player launches it instead, see updateInvader.
+func (g *Game) updateUFO() {
+	if g.ufoValues() == nil {
+		return
+	}
+	if g.ufoScoreTicks > 0 {
+		g.ufoScoreTicks--
+	}
+
+	if !ufo.Status {
+		if g.mode == modeVersus {
+			return
+		}
+		g.ufoTimer++
+		if g.ufoTimer >= ruleset.UFOIntervalTicks && g.board().aliveAliens() >= ruleset.UFOMinAliens {
+			g.ufoTimer = 0
+			g.launchUFO()
+		}
+		return
+	}
+
+	ufo.Position.X += g.ufoDirection
+	if ufo.Position.X < -ufo.size.Dx() || ufo.Position.X > windowWidth {
+		ufo.Status = false
+		return
+	}
+
+	for _, p := range g.activePlayers() {
+		if !p.beam.Status || !collide(p.beam, ufo) {
+			continue
+		}
+		// The UFO's value comes from the shot count table
+		values := g.ufoValues()
+		g.ufoScore = values[p.shotsFired%len(values)]
+		g.ufoScoreTicks = 60
+		g.ufoScoreX = ufo.Position.X
+		p.score += g.ufoScore
+		g.checkExtraLife(p)
+		ufo.Status = false
+		if explosionSound != nil && !g.silent() {
+			explosionSound.Rewind()
+			explosionSound.Play()
+		}
+		p.resetBeam()
+		return
+	}
+}
+
+// launchUFO sends the UFO across the top of the screen.
+func (g *Game) launchUFO() {
+	// Like the cabinet, the UFO's direction follows the shot count
+	g.ufoDirection = 1
+	ufo.Position = image.Pt(-ufo.size.Dx(), ruleset.UFOY)
+	if g.player().shotsFired%2 == 1 {
+		g.ufoDirection = -1
+		ufo.Position.X = windowWidth
+	}
+	ufo.Status = true
+}
+
+// ufoValues is the UFO's score table, or nil when the ruleset has no UFO.
+func (g *Game) ufoValues() []int {
+	if ruleset.UFOValues == nil && g.mode == modeVersus {
+		return versusUFOValues
+	}
+	return ruleset.UFOValues
+}
+
+func (g *Game) drawUFO(screen *ebiten.Image) {
+	if ufo.Status {
+		drawSprite(screen, ufo.Filter, ufo.Position.X, ufo.Position.Y, roleUFO, nil)
+	}
+	if g.ufoScoreTicks > 0 {
+		y := ruleset.UFOY + ufo.size.Dy()
+		text.Draw(screen, fmt.Sprintf("%d", g.ufoScore), g.gameFont, g.ufoScoreX, y, paletteColour(ufoScoreColour, y))
+	}
+}
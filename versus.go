@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// In versus player 1 defends as usual and player 2 is the invader, driving the formation.
+const (
+	versusDefender = 0
+	versusInvader  = 1
+)
+
+const (
+	versusRoundsToWin    = 2   // Best of three
+	versusBudget         = 5   // Most bombs the invader can have banked
+	versusRechargeTicks  = 90  // Ticks to earn back one bomb
+	versusFireCooldown   = 20  // Ticks between bombs
+	versusMarchCooldown  = 8   // Ticks between formation steps
+	versusMarchStepScale = 4   // Each step is this many of the ruleset's march steps
+	versusUFOCooldown    = 900 // Ticks between UFOs
+)
+
+// versusUFOValues are used when the ruleset has no UFO table of its own.
+var versusUFOValues = []int{50, 100, 150, 300}
+
+// invaderKeys are the invader player's keys, on the left of the keyboard.
+var invaderKeys = struct {
+	Left, Right, Fire, March, UFO ebiten.Key
+}{ebiten.KeyA, ebiten.KeyD, ebiten.KeyW, ebiten.KeyS, ebiten.KeyE}
+
+// Versus is the state of a versus match.
+type Versus struct {
+	round         int // Current round, starting at 1
+	wins          [2]int
+	results       []int // Winner of each round so far
+	column        int   // Formation column the invader has picked
+	bombs         int   // Bombs left in the invader's budget
+	rechargeTimer int
+	fireCooldown  int
+	marchCooldown int
+	ufoCooldown   int
+}
+
+func newVersus() Versus {
+	return Versus{round: 1, bombs: versusBudget}
+}
+
+// updateInvader turns the invader player's input into the formation's marching and firing.
+func (g *Game) updateInvader() {
+	v := &g.versus
+	b := g.board()
+
+	if v.fireCooldown > 0 {
+		v.fireCooldown--
+	}
+	if v.marchCooldown > 0 {
+		v.marchCooldown--
+	}
+	if v.ufoCooldown > 0 {
+		v.ufoCooldown--
+	}
+	if v.bombs < versusBudget {
+		v.rechargeTimer++
+		if v.rechargeTimer >= versusRechargeTicks {
+			v.rechargeTimer = 0
+			v.bombs++
+		}
+	}
+
+	// The invader can also play on player 2's gamepad, see invaderPad
+	pad, hasPad := Controls{gamepad: versusInvader}.gamepadID()
+	padJust := func(button ebiten.StandardGamepadButton) bool {
+		return hasPad && inpututil.IsStandardGamepadButtonJustPressed(pad, button)
+	}
+
+	if inpututil.IsKeyJustPressed(invaderKeys.Right) || padJust(invaderPad.Right) {
+		v.column = (v.column + 1) % ruleset.Columns
+	}
+	if inpututil.IsKeyJustPressed(invaderKeys.Left) || padJust(invaderPad.Left) {
+		v.column = (v.column + ruleset.Columns - 1) % ruleset.Columns
+	}
+
+	march := ebiten.IsKeyPressed(invaderKeys.March) || (hasPad && ebiten.IsStandardGamepadButtonPressed(pad, invaderPad.March))
+	if march && v.marchCooldown == 0 && !b.cleared() {
+		v.marchCooldown = versusMarchCooldown
+		b.marchStep(ruleset.MarchStep * versusMarchStepScale)
+	}
+
+	if (inpututil.IsKeyJustPressed(invaderKeys.Fire) || padJust(invaderPad.Fire)) && v.fireCooldown == 0 && v.bombs > 0 {
+		// A column with nobody left in it can't fire, and doesn't use up the budget
+		if alien, ok := b.lowestAlien(v.column); ok {
+			b.dropBomb(alien)
+			v.bombs--
+			v.fireCooldown = versusFireCooldown
+		}
+	}
+
+	if (inpututil.IsKeyJustPressed(invaderKeys.UFO) || padJust(invaderPad.UFO)) && v.ufoCooldown == 0 && !ufo.Status {
+		v.ufoCooldown = versusUFOCooldown
+		g.launchUFO()
+	}
+}
+
+// endRound scores a round for the winner, then starts the next one or ends the match.
+func (g *Game) endRound(winner int) {
+	v := &g.versus
+	v.wins[winner]++
+	v.results = append(v.results, winner)
+	if v.wins[winner] >= versusRoundsToWin {
+		g.gameOver = true
+		if endGameSound != nil {
+			endGameSound.Rewind()
+			endGameSound.Play()
+		}
+		return
+	}
+
+	// Everyone starts the next round afresh
+	v.round++
+	v.bombs = versusBudget
+	v.rechargeTimer = 0
+	v.fireCooldown = 0
+	v.marchCooldown = 0
+	v.ufoCooldown = 0
+	g.players[versusDefender].lives = g.ship.Lives
+	g.resetGame()
+	g.startTurn()
+}
+
+// versusStandings is each player's name and rounds won.
+func (g *Game) versusStandings() string {
+	return fmt.Sprintf("%s %d  %s %d", g.players[versusDefender].name, g.versus.wins[versusDefender], g.players[versusInvader].name, g.versus.wins[versusInvader])
+}
+
+// drawInvaderCursor marks the column the invader player will fire from.
+func (g *Game) drawInvaderCursor(screen *ebiten.Image) {
+	b := g.board()
+	alien, ok := b.lowestAlien(g.versus.column)
+	if !ok {
+		return // Nothing left in the column to fire from
+	}
+	colour := playerColours[versusInvader]
+	if g.versus.bombs == 0 || g.versus.fireCooldown > 0 {
+		colour = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	}
+	y := alien.Position.Y + alien.size.Dy() + 2
+	ebitenutil.DrawRect(screen, float64(alien.Position.X), float64(y), float64(alien.size.Dx()), 2, paletteColour(colour, y))
+}
+
+// versusHUD is the top of the HUD in versus: the defender's score and what the
+// invader has left to throw at them, with the standings along the bottom.
+func (g *Game) versusHUD() ([]hudItem, []string) {
+	v := g.versus
+	ufoReady := tr("READY")
+	if v.ufoCooldown > 0 {
+		ufoReady = tr("%dS", (v.ufoCooldown+ebiten.TPS()-1)/ebiten.TPS())
+	}
+	invader := playerColours[versusInvader]
+	items := []hudItem{
+		{label: tr("ROUND"), value: strconv.Itoa(v.round), colour: color.White},
+		valueItem(tr("SCORE"), &g.hud.scores[versusDefender]),
+		{label: tr("BOMBS"), value: tr("%d OF %d", v.bombs, versusBudget), colour: invader},
+		{label: tr("UFO"), value: ufoReady, colour: invader},
+	}
+	return items, []string{g.versusStandings()}
+}
+
+// drawVersusResults is the results screen at the end of a match.
+func (g *Game) drawVersusResults(screen *ebiten.Image) {
+	winner := versusDefender
+	if g.versus.wins[versusInvader] > g.versus.wins[versusDefender] {
+		winner = versusInvader
+	}
+	title := tr("%s WINS", g.players[winner].name)
+	if g.versus.wins[versusDefender] == g.versus.wins[versusInvader] {
+		title = tr("MATCH ABANDONED") // Quit before anyone won
+	}
+	lines := []string{title, g.versusStandings(), ""}
+	for i, result := range g.versus.results {
+		line := tr("Round %d  %s the defender", i+1, g.players[result].name)
+		if result == versusInvader {
+			line = tr("Round %d  %s the invader", i+1, g.players[result].name)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", tr("Press %s to Play again", keyName(actionConfirm)), tr("Press Esc to close the game"))
+
+	y := windowHeight / 4
+	for i, line := range lines {
+		face := g.gameFont
+		if i == 0 {
+			face = g.gameOverFont
+		}
+		bounds := text.BoundString(face, line)
+		text.Draw(screen, line, face, (windowWidth-bounds.Dx())/2, y, color.White)
+		y += text.BoundString(g.gameFont, "A").Dy() * 2
+		if i == 0 {
+			y += bounds.Dy()
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Just enough of WebSocket (RFC 6455) for the spectator stream: text messages from
+// the server, and the close and ping messages that keep a connection tidy.
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// WebSocket close codes, for why a connection was failed.
+const (
+	wsProtocolError   = 1002
+	wsUnsupportedData = 1003
+)
+
+const wsMaxMessage = 1 << 20 // Far bigger than any spectator frame
+
+// wsGUID is fixed by the WebSocket standard.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsAccept is the answer the server gives to a client's Sec-WebSocket-Key.
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsUpgrade turns an HTTP request into a WebSocket connection, on the server side.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "This is a spectator stream, watch it with -spectate ws://host:port", http.StatusBadRequest)
+		return nil, nil, errors.New("not a WebSocket request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Can't upgrade this connection", http.StatusInternalServerError)
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// wsDial opens a WebSocket connection to a ws:// address, on the client side.
+func wsDial(address string) (net.Conn, *bufio.Reader, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, nil, fmt.Errorf("%s: only ws:// addresses are supported", address)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, 5*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%s is not a spectator stream (%s)", address, resp.Status)
+	}
+	return conn, r, nil
+}
+
+// wsWriteFrame sends one unfragmented frame. Clients must mask what they send, servers mustn't.
+func wsWriteFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	header := []byte{0x80 | opcode, 0}
+	switch size := len(payload); {
+	case size < 126:
+		header[1] = byte(size)
+	case size <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(size))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(size))
+	}
+	if masked {
+		header[1] |= 0x80
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		header = append(header, mask...)
+		data := make([]byte, len(payload))
+		for i, b := range payload {
+			data[i] = b ^ mask[i%4]
+		}
+		payload = data
+	}
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// wsReadFrame reads one frame, unmasking it if needed.
+func wsReadFrame(r *bufio.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(r, extended[:]); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(r, extended[:]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(extended[:])
+	}
+	if size > wsMaxMessage {
+		err = errors.New("WebSocket message too big")
+		return
+	}
+	var mask [4]byte
+	masked := header[1]&0x80 != 0
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// wsReadMessage reads the next whole text message, putting fragmented ones back
+// together and answering pings on the way. w is where pongs go. Anything that
+// isn't a text message fails the connection, see wsFail.
+func wsReadMessage(r *bufio.Reader, w io.Writer, masked bool) ([]byte, error) {
+	var message []byte
+	started := false // A text frame has started the message
+	for {
+		fin, opcode, payload, err := wsReadFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsClose:
+			wsWriteFrame(w, wsClose, nil, masked)
+			return nil, io.EOF
+		case wsPing:
+			if err := wsWriteFrame(w, wsPong, payload, masked); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsText:
+			if started {
+				return nil, wsFail(w, wsProtocolError, masked, "WebSocket text message inside another")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, wsFail(w, wsProtocolError, masked, "WebSocket continuation with no message to continue")
+			}
+		case wsBinary:
+			return nil, wsFail(w, wsUnsupportedData, masked, "WebSocket binary messages aren't supported")
+		default:
+			return nil, wsFail(w, wsProtocolError, masked, fmt.Sprintf("unknown WebSocket opcode %#x", opcode))
+		}
+		message = append(message, payload...)
+		if len(message) > wsMaxMessage {
+			return nil, errors.New("WebSocket message too big")
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// wsFail closes the connection with a close code saying why, and returns the reason.
+func wsFail(w io.Writer, code uint16, masked bool, reason string) error {
+	wsWriteFrame(w, wsClose, binary.BigEndian.AppendUint16(nil, code), masked)
+	return errors.New(reason)
+}
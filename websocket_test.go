@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWSAccept(t *testing.T) {
+	// The example from RFC 6455
+	if got, want := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("wsAccept = %q, want %q", got, want)
+	}
+}
+
+func TestWSFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 125, 126, 0xffff, 0x10000} {
+		for _, masked := range []bool{false, true} {
+			payload := bytes.Repeat([]byte("invader"), size/7+1)[:size]
+			var buf bytes.Buffer
+			if err := wsWriteFrame(&buf, wsText, payload, masked); err != nil {
+				t.Fatal(err)
+			}
+			if masked && size > 0 && bytes.Contains(buf.Bytes(), payload) {
+				t.Errorf("size %d: masked frame has the payload in the clear", size)
+			}
+			fin, opcode, got, err := wsReadFrame(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("size %d masked %v: %v", size, masked, err)
+			}
+			if !fin || opcode != wsText || !bytes.Equal(got, payload) {
+				t.Errorf("size %d masked %v: read fin %v opcode %d and %d bytes", size, masked, fin, opcode, len(got))
+			}
+		}
+	}
+}
+
+func TestWSReadFrameTooBig(t *testing.T) {
+	header := binary.BigEndian.AppendUint64([]byte{0x80 | wsText, 127}, wsMaxMessage+1)
+	if _, _, _, err := wsReadFrame(bufio.NewReader(bytes.NewReader(header))); err == nil {
+		t.Error("read a frame bigger than wsMaxMessage")
+	}
+}
+
+// wsFrame is a frame as wsWriteFrame would send it, but with fin as given.
+func wsFrame(t *testing.T, fin bool, opcode byte, payload string, masked bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := wsWriteFrame(&buf, opcode, []byte(payload), masked); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+	if !fin {
+		frame[0] &^= 0x80
+	}
+	return frame
+}
+
+func TestWSReadMessage(t *testing.T) {
+	type frame struct {
+		fin     bool
+		opcode  byte
+		payload string
+	}
+	tests := []struct {
+		name      string
+		frames    []frame
+		want      string
+		wantErr   bool
+		wantReply []byte // Opcode and payload of the frame sent back, if any
+	}{
+		{name: "text", frames: []frame{{true, wsText, "hello"}}, want: "hello"},
+		{name: "empty", frames: []frame{{true, wsText, ""}}, want: ""},
+		{
+			name:   "fragmented",
+			frames: []frame{{false, wsText, "hel"}, {false, wsContinuation, "lo "}, {true, wsContinuation, "there"}},
+			want:   "hello there",
+		},
+		{
+			name:      "ping in the middle",
+			frames:    []frame{{false, wsText, "hel"}, {true, wsPing, "are you there"}, {true, wsContinuation, "lo"}},
+			want:      "hello",
+			wantReply: append([]byte{wsPong}, "are you there"...),
+		},
+		{name: "pong ignored", frames: []frame{{true, wsPong, ""}, {true, wsText, "hi"}}, want: "hi"},
+		{name: "close", frames: []frame{{true, wsClose, ""}}, wantErr: true, wantReply: []byte{wsClose}},
+		{
+			name:      "binary",
+			frames:    []frame{{true, wsBinary, "\x00\x01"}},
+			wantErr:   true,
+			wantReply: []byte{wsClose, wsUnsupportedData >> 8, wsUnsupportedData & 0xff},
+		},
+		{
+			name:      "binary then continuation",
+			frames:    []frame{{false, wsBinary, "\x00"}, {true, wsContinuation, "text"}},
+			wantErr:   true,
+			wantReply: []byte{wsClose, wsUnsupportedData >> 8, wsUnsupportedData & 0xff},
+		},
+		{
+			name:      "unknown opcode",
+			frames:    []frame{{true, 0x3, "what"}},
+			wantErr:   true,
+			wantReply: []byte{wsClose, wsProtocolError >> 8, wsProtocolError & 0xff},
+		},
+		{
+			name:      "continuation first",
+			frames:    []frame{{true, wsContinuation, "orphan"}},
+			wantErr:   true,
+			wantReply: []byte{wsClose, wsProtocolError >> 8, wsProtocolError & 0xff},
+		},
+		{
+			name:      "text inside text",
+			frames:    []frame{{false, wsText, "one"}, {true, wsText, "two"}},
+			wantErr:   true,
+			wantReply: []byte{wsClose, wsProtocolError >> 8, wsProtocolError & 0xff},
+		},
+		{name: "cut off", frames: []frame{{false, wsText, "hel"}}, wantErr: true},
+	}
+	for _, test := range tests {
+		for side, masked := range map[string]bool{"server": false, "client": true} {
+			t.Run(test.name+" "+side, func(t *testing.T) {
+				var stream bytes.Buffer
+				for _, f := range test.frames {
+					stream.Write(wsFrame(t, f.fin, f.opcode, f.payload, !masked)) // From the other end
+				}
+				var replies bytes.Buffer
+				got, err := wsReadMessage(bufio.NewReader(&stream), &replies, masked)
+				if test.wantErr {
+					if err == nil {
+						t.Fatalf("read %q, want an error", got)
+					}
+				} else if err != nil || string(got) != test.want {
+					t.Fatalf("read %q, %v, want %q", got, err, test.want)
+				}
+
+				if test.wantReply == nil {
+					if replies.Len() > 0 {
+						t.Errorf("sent %d bytes back, want nothing", replies.Len())
+					}
+					return
+				}
+				r := bufio.NewReader(&replies)
+				_, opcode, payload, err := wsReadFrame(r)
+				if err != nil {
+					t.Fatalf("reading the reply: %v", err)
+				}
+				if reply := append([]byte{opcode}, payload...); !bytes.Equal(reply, test.wantReply) {
+					t.Errorf("replied %v, want %v", reply, test.wantReply)
+				}
+				if _, err := r.Peek(1); err != io.EOF {
+					t.Error("sent more than one reply")
+				}
+			})
+		}
+	}
+}
+
+func TestWSReadMessageTooBig(t *testing.T) {
+	var stream bytes.Buffer
+	chunk := strings.Repeat("x", wsMaxMessage/2+1)
+	stream.Write(wsFrame(t, false, wsText, chunk, false))
+	stream.Write(wsFrame(t, true, wsContinuation, chunk, false))
+	if _, err := wsReadMessage(bufio.NewReader(&stream), io.Discard, true); err == nil {
+		t.Error("put together a message bigger than wsMaxMessage")
+	}
+}